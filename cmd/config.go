@@ -30,12 +30,13 @@ var configSetCmd = &cobra.Command{
 	Short: "Update configuration",
 	Long: `Update configuration values.
 If no arguments are provided, runs the interactive setup wizard.
-Supported keys: hostname, token
+Supported keys: hostname, token, auth, email, global-key
 
 Examples:
   cloudflare-ddns config set hostname=new.example.com
   cloudflare-ddns config set token
-  cloudflare-ddns config set hostname token`,
+  cloudflare-ddns config set hostname token
+  cloudflare-ddns config set auth=global_key email=admin@example.com global-key`,
 	RunE: runConfigSet,
 }
 
@@ -50,8 +51,81 @@ func runConfigShow(_ *cobra.Command, _ []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	fmt.Printf("Hostname: %s\n", cfg.Hostname)
+	records := cfg.AllRecords()
+	if len(records) == 0 {
+		fmt.Println("Hostname: <not configured>")
+	}
+	for _, r := range records {
+		fmt.Printf("Hostname: %s\n", r.Hostname)
+		fmt.Printf("  Types:      %s\n", strings.Join(r.RecordTypes(), ", "))
+		fmt.Printf("  On missing: %s\n", r.MissingPolicy())
+		fmt.Printf("  Proxied:    %s\n", r.ProxyMode())
+		fmt.Printf("  Duplicates: %s\n", r.DuplicatePolicy())
+		fmt.Printf("  On drift:   %s\n", r.DriftPolicy())
+		fmt.Printf("  Ownership:  %s\n", formatOwnership(r.OwnershipRequired()))
+		if r.TTL != 0 {
+			fmt.Printf("  TTL:        %s\n", r.TTL)
+		}
+		if r.ZoneID != "" {
+			fmt.Printf("  Zone ID:    %s\n", r.ZoneID)
+		}
+		if name := r.HeartbeatRecord(); name != "" {
+			fmt.Printf("  Heartbeat:  %s every %s\n", name, r.Heartbeat)
+		}
+		if r.IsEphemeral() {
+			if r.Lease > 0 {
+				fmt.Printf("  Ephemeral:  yes, lease %s\n", r.Lease)
+			} else {
+				fmt.Println("  Ephemeral:  yes")
+			}
+		}
+	}
+
+	for _, l := range cfg.AllIPLists() {
+		fmt.Printf("IP list:  %s\n", l.Name)
+		fmt.Printf("  Type:       %s\n", l.RecordType())
+		fmt.Printf("  Account ID: %s\n", l.AccountID)
+	}
+
+	for _, r := range cfg.AccessRules {
+		fmt.Printf("Access rule: %s\n", r.Zone)
+		fmt.Printf("  Notes:      %s\n", r.Notes)
+		fmt.Printf("  Mode:       %s\n", r.RuleMode())
+		fmt.Printf("  Type:       %s\n", r.RecordType())
+		if r.ZoneID != "" {
+			fmt.Printf("  Zone ID:    %s\n", r.ZoneID)
+		}
+	}
 
+	for _, o := range cfg.AllPoolOrigins() {
+		fmt.Printf("Load balancer origin: %s/%s\n", o.Pool, o.Origin)
+		fmt.Printf("  Type:       %s\n", o.RecordType())
+		fmt.Printf("  Account ID: %s\n", o.AccountID)
+	}
+
+	for _, l := range cfg.AllGatewayLocations() {
+		fmt.Printf("Gateway location: %s\n", l.Name)
+		fmt.Printf("  Account ID: %s\n", l.AccountID)
+	}
+
+	fmt.Printf("Machine:  %s\n", cfg.Machine())
+	fmt.Printf("IP from:  %s\n", strings.Join(cfg.IP.ProviderNames(), ", "))
+	if cfg.IP.DetectMode() == config.IPModeConsensus {
+		fmt.Printf("Quorum:   %d of %d must agree\n", cfg.IP.QuorumSize(), len(cfg.IP.ProviderNames()))
+	}
+
+	if cfg.AuthMode() == config.AuthGlobalKey {
+		fmt.Printf("Auth:     Global API Key (%s)\n", cfg.Email)
+		key, err := keychain.GetGlobalKey()
+		if err != nil {
+			fmt.Printf("Key:      <not configured or error: %v>\n", err)
+		} else {
+			fmt.Printf("Key:      %s\n", maskToken(key))
+		}
+		return nil
+	}
+
+	fmt.Println("Auth:     API token")
 	token, err := keychain.Get()
 	if err != nil {
 		fmt.Printf("Token:    <not configured or error: %v>\n", err)
@@ -67,14 +141,16 @@ func runConfigSet(_ *cobra.Command, args []string) error {
 		return setupFlow()
 	}
 
+	// Without a config file, Load starts us from an empty config. An invalid
+	// one is reported rather than replaced, so its other settings are kept.
 	cfg, err := config.Load()
 	if err != nil {
-		// If config doesn't exist, we start with an empty one
-		cfg = config.Config{}
+		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	var newToken string
+	var newToken, newGlobalKey string
 	tokenUpdated := false
+	globalKeyUpdated := false
 	configUpdated := false
 
 	for _, arg := range args {
@@ -114,12 +190,45 @@ func runConfigSet(_ *cobra.Command, args []string) error {
 			newToken = value
 			tokenUpdated = true
 
+		case "auth":
+			if value != config.AuthToken && value != config.AuthGlobalKey {
+				return fmt.Errorf("auth must be %s or %s", config.AuthToken, config.AuthGlobalKey)
+			}
+			cfg.Auth = value
+			configUpdated = true
+
+		case "email":
+			if value == "" {
+				return fmt.Errorf("email cannot be empty")
+			}
+			cfg.Email = value
+			configUpdated = true
+
+		case "global-key", "global_key":
+			if !hasValue {
+				fmt.Print("Cloudflare Global API Key: ")
+				bytePw, err := term.ReadPassword(int(os.Stdin.Fd()))
+				if err != nil {
+					return fmt.Errorf("failed to read Global API Key: %w", err)
+				}
+				fmt.Println() // newline after password input
+				value = strings.TrimSpace(string(bytePw))
+			}
+			if value == "" {
+				return fmt.Errorf("Global API Key cannot be empty")
+			}
+			newGlobalKey = value
+			globalKeyUpdated = true
+
 		default:
 			return fmt.Errorf("unknown configuration key: %s", key)
 		}
 	}
 
 	if configUpdated {
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
 		if err := config.Save(cfg); err != nil {
 			return fmt.Errorf("failed to save config: %w", err)
 		}
@@ -133,9 +242,23 @@ func runConfigSet(_ *cobra.Command, args []string) error {
 		fmt.Println("Token saved to keychain.")
 	}
 
+	if globalKeyUpdated {
+		if err := keychain.SetGlobalKey(newGlobalKey); err != nil {
+			return fmt.Errorf("failed to save Global API Key to keychain: %w", err)
+		}
+		fmt.Println("Global API Key saved to keychain.")
+	}
+
 	return nil
 }
 
+func formatOwnership(required bool) string {
+	if required {
+		return "required"
+	}
+	return "not required"
+}
+
 func maskToken(token string) string {
 	if len(token) <= 4 {
 		return "****"
@@ -73,10 +73,12 @@ type LogEntry struct {
 	Level    string `json:"level"`
 	Msg      string `json:"msg"`
 	Hostname string `json:"hostname,omitempty"`
+	Type     string `json:"type,omitempty"`
 	Error    string `json:"error,omitempty"`
 	OldIP    string `json:"oldIP,omitempty"`
 	NewIP    string `json:"newIP,omitempty"`
 	IP       string `json:"ip,omitempty"`
+	RetryAt  string `json:"retryAt,omitempty"`
 }
 
 func prettyPrintLogLine(line string) {
@@ -93,7 +95,9 @@ func prettyPrintLogLine(line string) {
 
 	// Build the message
 	msg := entry.Msg
-	if entry.Hostname != "" {
+	if entry.Hostname != "" && entry.Type != "" {
+		msg = fmt.Sprintf("%s [%s %s]", msg, entry.Hostname, entry.Type)
+	} else if entry.Hostname != "" {
 		msg = fmt.Sprintf("%s [%s]", msg, entry.Hostname)
 	}
 	if entry.OldIP != "" && entry.NewIP != "" {
@@ -101,6 +105,9 @@ func prettyPrintLogLine(line string) {
 	} else if entry.IP != "" {
 		msg = fmt.Sprintf("%s %s", msg, entry.IP)
 	}
+	if entry.RetryAt != "" {
+		msg = fmt.Sprintf("%s (retry after %s)", msg, formatTime(entry.RetryAt))
+	}
 	if entry.Error != "" {
 		msg = fmt.Sprintf("%s - %s", msg, entry.Error)
 	}
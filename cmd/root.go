@@ -33,6 +33,7 @@ func init() {
 	rootCmd.AddCommand(testCmd)
 	rootCmd.AddCommand(logsCmd)
 	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(tokenCmd)
 }
 
 func runRoot(_ *cobra.Command, _ []string) error {
@@ -40,13 +41,26 @@ func runRoot(_ *cobra.Command, _ []string) error {
 		_, _ = fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
 	}
 
-	// Check if config and keychain exist
-	_, err := keychain.Get()
-	if !config.Exists() || err != nil {
-		// Run setup
+	// Only a first run gets the setup wizard: it writes a fresh config, which
+	// would throw away the records, targets and auth settings of an existing one
+	if !config.Exists() {
 		return setupFlow()
 	}
 
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := checkCredentials(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		if cfg.AuthMode() == config.AuthGlobalKey {
+			fmt.Fprintln(os.Stderr, "  Store it with 'cloudflare-ddns config set global-key'.")
+		} else {
+			fmt.Fprintln(os.Stderr, "  Store it with 'cloudflare-ddns config set token'.")
+		}
+		return err
+	}
+
 	fmt.Println("Configuration found. Use 'cloudflare-ddns run' to start the daemon or 'cloudflare-ddns test' to verify.")
 	return nil
 }
@@ -92,17 +106,25 @@ func setupFlow() error {
 		return fmt.Errorf("failed to store API key: %w", err)
 	}
 
+	cfg := config.Config{Hostname: hostname}
+	if err := verifyToken(ctx, cfg); err != nil {
+		fmt.Printf("❌ Setup failed: %v\n", err)
+		return err
+	}
+
 	// Use RunOnceWithCreate to create the record if it doesn't exist
-	result := updater.RunOnceWithCreate(ctx, hostname)
-	if result.Error != nil {
-		fmt.Printf("❌ Setup failed: %v\n", result.Error)
-		return result.Error
+	result := updater.RunOnceWithCreate(ctx, cfg)
+	if err := result.Err(); err != nil {
+		fmt.Printf("❌ Setup failed: %v\n", err)
+		return err
 	}
 
 	fmt.Println("✓ Credentials validated successfully!")
-	fmt.Printf("  Current IP: %s\n", result.CurrentIP.String())
-	fmt.Printf("  DNS Record IP: %s\n", result.RecordIP.String())
-	if result.Updated {
+	for _, f := range result.Records[0].Families() {
+		fmt.Printf("  Current IP (%s): %s\n", f.RecordType, f.CurrentIP.String())
+		fmt.Printf("  DNS Record IP (%s): %s\n", f.RecordType, f.RecordIP.String())
+	}
+	if result.Updated() {
 		fmt.Println("  (DNS record was created)")
 	} else {
 		fmt.Println("  (DNS record was already up to date)")
@@ -110,7 +132,6 @@ func setupFlow() error {
 	fmt.Println()
 
 	// Save configuration
-	cfg := config.Config{Hostname: hostname}
 	if err := config.Save(cfg); err != nil {
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
@@ -121,6 +142,7 @@ func setupFlow() error {
 	fmt.Println("  cloudflare-ddns run     - Start the daemon (runs every 60 seconds)")
 	fmt.Println("  cloudflare-ddns test    - Test the connection and show current status")
 	fmt.Println("  cloudflare-ddns logs    - View recent log entries")
+	fmt.Println("  cloudflare-ddns token verify - Check the API token's permissions")
 	fmt.Println()
 	fmt.Println("To run as a background service on macOS:")
 	fmt.Println("  brew services start cloudflare-ddns")
@@ -137,4 +159,5 @@ func Execute() error {
 func SetVersion(v string) {
 	version = v
 	rootCmd.Version = v
+	updater.Version = v
 }
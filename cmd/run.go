@@ -2,17 +2,19 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/jon-frankel/cloudflare-ddns/internal/cloudflare"
 	"github.com/jon-frankel/cloudflare-ddns/internal/config"
-	"github.com/jon-frankel/cloudflare-ddns/internal/keychain"
 	"github.com/jon-frankel/cloudflare-ddns/internal/logger"
 	"github.com/jon-frankel/cloudflare-ddns/internal/updater"
 )
@@ -34,18 +36,23 @@ func doRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	if cfg.Hostname == "" {
+	names := append(cfg.Hostnames(), cfg.TargetNames()...)
+	if !cfg.Configured() {
 		return fmt.Errorf("hostname not configured; run 'cloudflare-ddns' to complete setup")
 	}
 
 	// Check keychain
-	_, err = keychain.Get()
+	if err := checkCredentials(cfg); err != nil {
+		return err
+	}
+
+	u, err := updater.New(cfg)
 	if err != nil {
-		return fmt.Errorf("API key not configured in keychain: %w", err)
+		return err
 	}
 
-	fmt.Printf("Starting DDNS update loop for %s\n", cfg.Hostname)
-	slog.Info("Starting DDNS update loop", "hostname", cfg.Hostname)
+	fmt.Printf("Starting DDNS update loop for %s\n", strings.Join(names, ", "))
+	slog.Info("Starting DDNS update loop", "targets", names)
 
 	// Setup signal handling
 	sigChan := make(chan os.Signal, 1)
@@ -55,9 +62,18 @@ func doRun(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Remove ephemeral records a crashed run left behind, then start owning them
+	for _, r := range u.Claim(ctx) {
+		logEphemeralResult(r)
+	}
+
 	// Run first update immediately
-	result := updater.RunOnce(ctx, cfg.Hostname)
-	logUpdateResult(cfg.Hostname, result)
+	result := u.RunOnce(ctx)
+	logCycleResult(result)
+	if err := stopError(ctx, u, result, true); err != nil {
+		return err
+	}
+	retryAt := result.RetryAt
 
 	// Start the update loop
 	ticker := time.NewTicker(60 * time.Second)
@@ -66,12 +82,23 @@ func doRun(cmd *cobra.Command, args []string) error {
 	for {
 		select {
 		case <-ticker.C:
-			result := updater.RunOnce(ctx, cfg.Hostname)
-			logUpdateResult(cfg.Hostname, result)
+			// Stay away from the API until the rate-limit backoff has passed
+			if time.Now().Before(retryAt) {
+				slog.Debug("Skipping update cycle while rate limited", "retryAt", retryAt.Format(time.RFC3339))
+				continue
+			}
+
+			result := u.RunOnce(ctx)
+			logCycleResult(result)
+			if err := stopError(ctx, u, result, false); err != nil {
+				return err
+			}
+			retryAt = result.RetryAt
 
 		case <-sigChan:
 			fmt.Println("\nShutting down...")
 			slog.Info("Shutting down DDNS update loop")
+			releaseEphemeral(u)
 			return nil
 
 		case <-ctx.Done():
@@ -80,16 +107,130 @@ func doRun(cmd *cobra.Command, args []string) error {
 	}
 }
 
-func logUpdateResult(hostname string, result updater.UpdateResult) {
+// stopError returns an error if the daemon should stop after result rather
+// than retry. Rejected credentials cannot recover, since they are only read at
+// startup: a 401, or a 403 after which Cloudflare no longer accepts the token
+// at all. A 403 for one zone or account is only a missing permission and is
+// reported for that record or target like any other permanent error. If the
+// first cycle failed permanently for every record the configuration is wrong.
+// Later permanent errors are only escalated in the log, as they may be fixed
+// in the Cloudflare dashboard while the daemon runs.
+func stopError(ctx context.Context, u *updater.Updater, result updater.CycleResult, first bool) error {
+	err := result.Err()
+	if errors.Is(err, cloudflare.ErrUnauthorized) || (errors.Is(err, cloudflare.ErrAuth) && u.CredentialsRejected(ctx)) {
+		slog.Error("Cloudflare rejected the credentials, stopping", "error", err)
+		return fmt.Errorf("Cloudflare rejected the credentials; run 'cloudflare-ddns token verify' for details: %w", err)
+	}
+	if first && result.Permanent() {
+		slog.Error("No configured record can be updated, stopping", "error", err)
+		return fmt.Errorf("no configured record can be updated; check the configuration: %w", err)
+	}
+	return nil
+}
+
+// releaseEphemeral deletes the ephemeral records on shutdown. It uses its own
+// deadline so a stuck API call cannot block the shutdown indefinitely.
+func releaseEphemeral(u *updater.Updater) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, r := range u.Release(ctx) {
+		logEphemeralResult(r)
+	}
+}
+
+func logEphemeralResult(result updater.EphemeralResult) {
+	hostname := result.Hostname
+	if result.Stale != nil {
+		fmt.Printf("⚠ %s lease expired (last check %s by %s)\n", hostname, result.Stale.LastCheck.Local().Format(time.RFC3339), result.Stale.Host)
+	}
 	if result.Error != nil {
-		slog.Error("Update cycle failed", "hostname", hostname, "error", result.Error)
+		fmt.Printf("❌ %s ephemeral record removal failed: %v\n", hostname, result.Error)
+		return
+	}
+	for _, ip := range result.Deleted {
+		fmt.Printf("✓ %s ephemeral record deleted: %s\n", hostname, ip)
+	}
+}
+
+func logCycleResult(result updater.CycleResult) {
+	if !result.RetryAt.IsZero() {
+		slog.Warn("Rate limited by Cloudflare, pausing updates", "retryAt", result.RetryAt.Format(time.RFC3339))
+		fmt.Printf("⚠ Rate limited by Cloudflare; next attempt after %s\n", result.RetryAt.Format("15:04:05"))
+	}
+
+	if result.Error != nil {
+		slog.Error("Update cycle failed", "error", result.Error)
 		fmt.Printf("❌ Update failed: %v\n", result.Error)
 		return
 	}
 
-	if result.Updated {
-		fmt.Printf("✓ DNS record updated: %s -> %s\n", result.OldIP, result.CurrentIP)
-	} else {
-		fmt.Printf("ℹ DNS record is current: %s\n", result.CurrentIP)
+	for _, r := range result.Records {
+		logUpdateResult(r)
+	}
+	for _, t := range result.Targets {
+		logTargetResult(t)
+	}
+}
+
+func logUpdateResult(result updater.UpdateResult) {
+	hostname := result.Hostname
+	for _, f := range result.Families() {
+		switch {
+		case cloudflare.IsPermanent(f.Error):
+			slog.Error("Update needs attention, retrying will not help until it is fixed", "hostname", hostname, "type", f.RecordType, "error", f.Error)
+			fmt.Printf("❌ %s %s update failed and needs attention: %v\n", hostname, f.RecordType, f.Error)
+		case f.Error != nil:
+			slog.Error("Update cycle failed", "hostname", hostname, "type", f.RecordType, "error", f.Error)
+			fmt.Printf("❌ %s %s update failed: %v\n", hostname, f.RecordType, f.Error)
+		case f.Paused:
+			fmt.Printf("⚠ %s %s record was changed outside cloudflare-ddns to %s; management paused until restart\n", hostname, f.RecordType, f.RecordIP)
+		case f.NoIPv6 && f.Deleted:
+			fmt.Printf("✓ %s %s record deleted (no IPv6 connectivity): %s\n", hostname, f.RecordType, f.OldIP)
+		case f.Deleted:
+			fmt.Printf("✓ %s %s record deleted (no public address): %s\n", hostname, f.RecordType, f.OldIP)
+		case f.NoIPv6:
+			fmt.Printf("⚠ %s %s record left unchanged (no IPv6 connectivity)\n", hostname, f.RecordType)
+		case f.Missing:
+			fmt.Printf("⚠ %s %s record left unchanged (no public address)\n", hostname, f.RecordType)
+		case f.Created:
+			fmt.Printf("✓ %s %s record created: %s\n", hostname, f.RecordType, f.CurrentIP)
+		case f.Updated && f.Drift:
+			fmt.Printf("✓ %s %s record overwritten after an outside change: %s -> %s\n", hostname, f.RecordType, f.OldIP, f.CurrentIP)
+		case f.Updated:
+			fmt.Printf("✓ %s %s record updated: %s -> %s\n", hostname, f.RecordType, f.OldIP, f.CurrentIP)
+		default:
+			fmt.Printf("ℹ %s %s record is current: %s\n", hostname, f.RecordType, f.CurrentIP)
+		}
+		if f.DuplicatesDeleted > 0 {
+			fmt.Printf("✓ %s %s: deleted %d duplicate record(s)\n", hostname, f.RecordType, f.DuplicatesDeleted)
+		}
+	}
+
+	if hb := result.Heartbeat; hb != nil {
+		switch {
+		case hb.Error != nil:
+			slog.Error("Heartbeat refresh failed", "hostname", hostname, "name", hb.Name, "error", hb.Error)
+			fmt.Printf("❌ %s heartbeat refresh failed: %v\n", hostname, hb.Error)
+		case hb.Refreshed:
+			fmt.Printf("ℹ %s heartbeat refreshed: %s\n", hostname, hb.Name)
+		}
+	}
+}
+
+func logTargetResult(result updater.TargetResult) {
+	switch {
+	case cloudflare.IsPermanent(result.Error):
+		slog.Error("Update needs attention, retrying will not help until it is fixed", "target", result.String(), "error", result.Error)
+		fmt.Printf("❌ %s update failed and needs attention: %v\n", result, result.Error)
+	case result.Error != nil:
+		slog.Error("Update cycle failed", "target", result.String(), "error", result.Error)
+		fmt.Printf("❌ %s update failed: %v\n", result, result.Error)
+	case result.Created:
+		fmt.Printf("✓ %s entry added: %s\n", result, result.Value)
+	case result.Updated:
+		fmt.Printf("✓ %s updated: %s -> %s\n", result, result.OldValue, result.Value)
+	default:
+		fmt.Printf("ℹ %s is current: %s\n", result, result.Value)
 	}
 }
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/jon-frankel/cloudflare-ddns/internal/cloudflare"
 	"github.com/jon-frankel/cloudflare-ddns/internal/config"
-	"github.com/jon-frankel/cloudflare-ddns/internal/keychain"
+	"github.com/jon-frankel/cloudflare-ddns/internal/ip"
 	"github.com/jon-frankel/cloudflare-ddns/internal/logger"
 	"github.com/jon-frankel/cloudflare-ddns/internal/updater"
 )
@@ -32,53 +34,162 @@ func doTest(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if cfg.Hostname == "" {
+	names := append(cfg.Hostnames(), cfg.TargetNames()...)
+	if !cfg.Configured() {
 		fmt.Fprintf(os.Stderr, "❌ Hostname not configured; run 'cloudflare-ddns' to complete setup\n")
 		return fmt.Errorf("hostname not configured")
 	}
 
 	// Check keychain
-	_, err = keychain.Get()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "❌ API key not configured in keychain: %v\n", err)
+	if err := checkCredentials(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
 		return err
 	}
 
-	fmt.Printf("Testing configuration for: %s\n", cfg.Hostname)
+	fmt.Printf("Testing configuration for: %s\n", strings.Join(names, ", "))
 	fmt.Println()
 
 	// Run update
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	result := updater.RunOnce(ctx, cfg.Hostname)
+	if err := verifyToken(ctx, cfg); err != nil {
+		return err
+	}
+
+	result := updater.RunOnce(ctx, cfg)
 
-	fmt.Printf("Hostname:            %s\n", cfg.Hostname)
-	if result.CurrentIP != nil {
-		fmt.Printf("Current IP:          %s\n", result.CurrentIP.String())
+	if result.Error != nil {
+		fmt.Printf("❌ Test failed: %v\n", result.Error)
+		return result.Error
+	}
+
+	for _, r := range result.Records {
+		printUpdateResult(r)
+	}
+	for _, t := range result.Targets {
+		printTargetResult(t)
+	}
+
+	if err := result.Err(); err != nil {
+		fmt.Printf("❌ Test failed: %v\n", err)
+		return err
 	}
-	if result.RecordIP != nil {
-		fmt.Printf("DNS Record IP:       %s\n", result.RecordIP.String())
+
+	if result.Updated() {
+		fmt.Printf("✓ DNS records updated successfully!\n")
+	} else {
+		fmt.Printf("✓ DNS records are already up to date\n")
 	}
-	if result.RecordProxied != nil {
-		proxiedStr := "disabled"
-		if *result.RecordProxied {
-			proxiedStr = "enabled ✓"
+
+	return nil
+}
+
+func printUpdateResult(result updater.UpdateResult) {
+	fmt.Printf("Hostname:            %s\n", result.Hostname)
+	for _, f := range result.Families() {
+		fmt.Printf("  Record Type:       %s\n", f.RecordType)
+		switch {
+		case f.NoIPv6:
+			fmt.Printf("  Current IP:        <no IPv6 connectivity>\n")
+		case f.Missing:
+			fmt.Printf("  Current IP:        <not available>\n")
+		}
+		if f.CurrentIP != nil {
+			fmt.Printf("  Current IP:        %s (via %s)\n", f.CurrentIP.String(), f.Provider)
+		}
+		printTrace(f.Trace)
+		if f.RecordIP != nil {
+			fmt.Printf("  DNS Record IP:     %s\n", f.RecordIP.String())
+		}
+		if f.Deleted {
+			fmt.Printf("  DNS Record:        deleted (was %s)\n", f.OldIP.String())
+		}
+		if f.RecordProxied != nil {
+			proxiedStr := "disabled"
+			if *f.RecordProxied {
+				proxiedStr = "enabled ✓"
+			}
+			fmt.Printf("  Cloudflare Proxy:  %s\n", proxiedStr)
+		}
+		if f.RecordTTL != 0 {
+			fmt.Printf("  TTL:               %s\n", config.TTL(f.RecordTTL))
+		}
+		if f.RecordIP != nil {
+			if owner, ok := cloudflare.ParseOwnership(f.RecordComment); ok {
+				fmt.Printf("  Managed By:        %s (last update %s)\n", owner.Host, owner.Updated.Local().Format(time.DateTime))
+			} else {
+				fmt.Printf("  Managed By:        <no cloudflare-ddns marker>\n")
+			}
+		}
+		if len(f.Records) > 1 {
+			fmt.Printf("  Matching Records:  %d\n", len(f.Records))
+			for _, rec := range f.Records {
+				fmt.Printf("    - %s  %-39s  proxied=%s  ttl=%s\n", rec.ID, rec.IP, formatProxied(rec.Proxied), config.TTL(rec.TTL))
+			}
+		}
+		if f.DuplicatesDeleted > 0 {
+			fmt.Printf("  Duplicates:        %d deleted\n", f.DuplicatesDeleted)
+		}
+		if f.Error != nil {
+			fmt.Printf("  Error:             %v\n", f.Error)
+		}
+	}
+	if hb := result.Heartbeat; hb != nil {
+		if hb.Error != nil {
+			fmt.Printf("  Heartbeat:         %s (error: %v)\n", hb.Name, hb.Error)
+		} else {
+			fmt.Printf("  Heartbeat:         %s (last check %s, version %s)\n", hb.Name, hb.Heartbeat.LastCheck.Local().Format(time.DateTime), hb.Heartbeat.Version)
 		}
-		fmt.Printf("Cloudflare Proxy:    %s\n", proxiedStr)
 	}
 	fmt.Println()
+}
 
+func printTargetResult(result updater.TargetResult) {
+	fmt.Printf("%-21s%s\n", result.Kind+":", result.Name)
+	fmt.Printf("  Record Type:       %s\n", result.RecordType)
+	switch {
+	case result.NoIPv6:
+		fmt.Printf("  Current IP:        <no IPv6 connectivity>\n")
+	case result.Missing:
+		fmt.Printf("  Current IP:        <not available>\n")
+	}
+	if result.CurrentIP != nil {
+		fmt.Printf("  Current IP:        %s (via %s)\n", result.CurrentIP.String(), result.Provider)
+	}
+	printTrace(result.Trace)
+	if result.Value != "" {
+		fmt.Printf("  Target Value:      %s\n", result.Value)
+	}
+	if result.OldValue != "" {
+		fmt.Printf("  Previous Value:    %s\n", result.OldValue)
+	}
 	if result.Error != nil {
-		fmt.Printf("❌ Test failed: %v\n", result.Error)
-		return result.Error
+		fmt.Printf("  Error:             %v\n", result.Error)
 	}
+	fmt.Println()
+}
 
-	if result.Updated {
-		fmt.Printf("✓ DNS record updated successfully!\n")
-	} else {
-		fmt.Printf("✓ DNS record is already up to date\n")
+func formatProxied(proxied *bool) string {
+	if proxied == nil {
+		return "unknown"
 	}
+	if *proxied {
+		return "on"
+	}
+	return "off"
+}
 
-	return nil
+// printTrace prints the Cloudflare edge that answered the cloudflare IP
+// provider, and warns when WARP hides the machine's own address.
+func printTrace(trace *ip.Trace) {
+	if trace == nil {
+		return
+	}
+	fmt.Printf("  Cloudflare Colo:   %s\n", trace.Colo)
+	if trace.Warp == "" || trace.Warp == "off" {
+		fmt.Printf("  WARP:              off\n")
+		return
+	}
+	fmt.Printf("  WARP:              %s ⚠ the address is WARP's, not this machine's\n", trace.Warp)
 }
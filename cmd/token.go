@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
+	"github.com/jon-frankel/cloudflare-ddns/internal/keychain"
+	"github.com/jon-frankel/cloudflare-ddns/internal/updater"
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Inspect the Cloudflare API token",
+}
+
+var tokenVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the API token's status, expiry and permissions",
+	Long: `Verify the API token stored in the keychain with Cloudflare and check that it
+can read the zone and edit the DNS records of every configured hostname.
+Nothing is changed in Cloudflare.`,
+	RunE: runTokenVerify,
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenVerifyCmd)
+}
+
+func runTokenVerify(_ *cobra.Command, _ []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if err := checkCredentials(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return verifyToken(ctx, cfg)
+}
+
+// verifyToken checks the token against cfg, prints the outcome and returns
+// an error explaining what is wrong if the token cannot manage the records.
+func verifyToken(ctx context.Context, cfg config.Config) error {
+	result := updater.Verify(ctx, cfg)
+	if result.Error != nil {
+		if cfg.AuthMode() == config.AuthGlobalKey {
+			fmt.Printf("❌ Global API Key verification failed: %v\n", result.Error)
+			fmt.Println("  Check that the key and the email belong to the same Cloudflare account.")
+			return result.Error
+		}
+		fmt.Printf("❌ Token verification failed: %v\n", result.Error)
+		fmt.Println("  Check that the token was copied correctly and has not been revoked.")
+		return result.Error
+	}
+
+	if result.AuthMode == config.AuthGlobalKey {
+		return printChecks(result)
+	}
+
+	token := result.Token
+	fmt.Printf("Token:               %s\n", token.Status)
+	if !token.NotBefore.IsZero() {
+		fmt.Printf("  Not Before:        %s\n", token.NotBefore.Local().Format(time.DateTime))
+	}
+	if token.ExpiresOn.IsZero() {
+		fmt.Printf("  Expires:           never\n")
+	} else {
+		fmt.Printf("  Expires:           %s\n", token.ExpiresOn.Local().Format(time.DateTime))
+	}
+	if !token.Active() {
+		fmt.Printf("❌ Token is %s; create a new one in the Cloudflare dashboard\n", token.Status)
+		return fmt.Errorf("API token is %s", token.Status)
+	}
+	return printChecks(result)
+}
+
+// printChecks prints the per-hostname permission checks of result and
+// returns an error if the credentials cannot manage every record.
+func printChecks(result updater.VerifyResult) error {
+	if result.AuthMode == config.AuthGlobalKey {
+		fmt.Printf("Global API Key:      valid for %s\n", result.Email)
+	}
+
+	for _, check := range result.Checks {
+		switch {
+		case check.Error != nil:
+			fmt.Printf("  %-18s ❌ %v\n", check.Hostname+":", check.Error)
+		case len(check.Missing) > 0:
+			fmt.Printf("  %-18s ❌ missing %s\n", check.Hostname+":", strings.Join(check.Missing, ", "))
+		default:
+			fmt.Printf("  %-18s ✓ zone %s\n", check.Hostname+":", check.ZoneID)
+		}
+	}
+	fmt.Println()
+
+	if !result.OK() && result.AuthMode == config.AuthGlobalKey {
+		fmt.Println("❌ The account cannot manage every configured record.")
+		fmt.Println("  Check that each hostname's zone belongs to the account or is shared with it.")
+		return fmt.Errorf("Global API Key cannot manage every configured record")
+	}
+	if !result.OK() {
+		fmt.Println("❌ The token cannot manage every configured record.")
+		fmt.Println("  Grant the missing permissions to the token for the hostname's zone in the")
+		fmt.Println("  Cloudflare dashboard (My Profile → API Tokens), then try again.")
+		return fmt.Errorf("API token is missing permissions")
+	}
+	return nil
+}
+
+// checkCredentials returns an error if the secret for cfg's auth mode is not
+// in the keychain: the API token, or the Global API Key with auth = "global_key".
+func checkCredentials(cfg config.Config) error {
+	if cfg.AuthMode() == config.AuthGlobalKey {
+		if _, err := keychain.GetGlobalKey(); err != nil {
+			return fmt.Errorf("Global API Key not configured in keychain: %w", err)
+		}
+		return nil
+	}
+	if _, err := keychain.Get(); err != nil {
+		return fmt.Errorf("API key not configured in keychain: %w", err)
+	}
+	return nil
+}
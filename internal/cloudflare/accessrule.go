@@ -0,0 +1,137 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+
+	cf "github.com/cloudflare/cloudflare-go"
+)
+
+// AccessRule is a zone-level WAF IP Access Rule for a single address.
+type AccessRule struct {
+	ID     string
+	ZoneID string
+	// Mode is the action, e.g. "whitelist" or "block".
+	Mode string
+	// Value is the address the rule matches.
+	Value string
+	Notes string
+}
+
+// accessRuleTarget returns the access rule configuration target for ip.
+func accessRuleTarget(ip net.IP) string {
+	if ip.To4() != nil {
+		return "ip"
+	}
+	return "ip6"
+}
+
+// FindAccessRules fetches the zone's IP access rules whose notes equal notes.
+// The zone is given by name, or by any hostname in it. There is normally one,
+// but a replacement whose old rule could not be deleted leaves two. Returns an
+// error matching ErrTargetNotFound if there is none.
+func (c *Client) FindAccessRules(ctx context.Context, zone, notes string) ([]*AccessRule, error) {
+	zoneID, err := c.getZoneID(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	// The notes filter matches substrings, so compare the notes exactly below
+	var rules []*AccessRule
+	filter := cf.AccessRule{Notes: notes}
+	for page := 1; ; page++ {
+		resp, err := c.api.ListZoneAccessRules(ctx, zoneID, filter, page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list access rules: %w", classify(err, ErrZoneNotFound))
+		}
+		for _, rule := range resp.Result {
+			if rule.Notes != notes || (rule.Configuration.Target != "ip" && rule.Configuration.Target != "ip6") {
+				continue
+			}
+			rules = append(rules, &AccessRule{
+				ID:     rule.ID,
+				ZoneID: zoneID,
+				Mode:   rule.Mode,
+				Value:  rule.Configuration.Value,
+				Notes:  rule.Notes,
+			})
+		}
+		if page >= resp.TotalPages {
+			break
+		}
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("%w: no IP access rule with notes %q in %s", ErrTargetNotFound, notes, zone)
+	}
+	return rules, nil
+}
+
+// CreateAccessRule creates an IP access rule in the zone matching ip.
+func (c *Client) CreateAccessRule(ctx context.Context, zone, mode string, ip net.IP, notes string) (*AccessRule, error) {
+	zoneID, err := c.getZoneID(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("Creating access rule", "zone", zone, "mode", mode, "ip", ip.String(), "notes", notes)
+
+	resp, err := c.api.CreateZoneAccessRule(ctx, zoneID, cf.AccessRule{
+		Mode:          mode,
+		Notes:         notes,
+		Configuration: cf.AccessRuleConfiguration{Target: accessRuleTarget(ip), Value: ip.String()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create access rule: %w", classify(err, ErrZoneNotFound))
+	}
+
+	return &AccessRule{
+		ID:     resp.Result.ID,
+		ZoneID: zoneID,
+		Mode:   resp.Result.Mode,
+		Value:  resp.Result.Configuration.Value,
+		Notes:  resp.Result.Notes,
+	}, nil
+}
+
+// ReplaceAccessRule points rule at ip with the given mode. Cloudflare does not
+// allow changing the address of an access rule, so a new rule with the same
+// notes is created first and the old one is deleted after, leaving no gap in
+// which the address is not matched. Returns the new rule, also when only the
+// delete failed.
+func (c *Client) ReplaceAccessRule(ctx context.Context, zone string, rule *AccessRule, mode string, ip net.IP) (*AccessRule, error) {
+	created, err := c.CreateAccessRule(ctx, zone, mode, ip, rule.Notes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.DeleteAccessRule(ctx, rule); err != nil {
+		return created, err
+	}
+	return created, nil
+}
+
+// DeleteAccessRule removes an access rule.
+func (c *Client) DeleteAccessRule(ctx context.Context, rule *AccessRule) error {
+	slog.Debug("Deleting access rule", "zoneID", rule.ZoneID, "id", rule.ID, "ip", rule.Value)
+
+	if _, err := c.api.DeleteZoneAccessRule(ctx, rule.ZoneID, rule.ID); err != nil {
+		return fmt.Errorf("failed to delete access rule %s: %w", rule.ID, classify(err, ErrTargetNotFound))
+	}
+	return nil
+}
+
+// SetAccessRuleMode changes the action of an existing access rule.
+func (c *Client) SetAccessRuleMode(ctx context.Context, rule *AccessRule, mode string) (*AccessRule, error) {
+	slog.Debug("Updating access rule mode", "id", rule.ID, "oldMode", rule.Mode, "newMode", mode)
+
+	resp, err := c.api.UpdateZoneAccessRule(ctx, rule.ZoneID, rule.ID, cf.AccessRule{Mode: mode, Notes: rule.Notes})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update access rule: %w", classify(err, ErrTargetNotFound))
+	}
+
+	updated := *rule
+	updated.Mode = resp.Result.Mode
+	return &updated, nil
+}
@@ -0,0 +1,69 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestReplaceAccessRule(t *testing.T) {
+	var created, deleted string
+
+	srv := newFakeAPI(t, oneRecord)
+	mux := srv.Config.Handler.(*http.ServeMux)
+	mux.HandleFunc("/client/v4/zones/zone-1/firewall/access_rules/rules", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			if got := r.URL.Query().Get("notes"); got != "home" {
+				t.Errorf("Expected notes filter home, got %q", got)
+			}
+			// The notes filter matches substrings; only the exact match counts
+			fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],"result":[
+				{"id":"rule-0","notes":"home (old)","mode":"block","configuration":{"target":"ip","value":"192.0.2.1"}},
+				{"id":"rule-1","notes":"home","mode":"whitelist","configuration":{"target":"ip","value":"198.51.100.89"}}],
+				"result_info":{"page":1,"per_page":100,"count":2,"total_count":2,"total_pages":1}}`)
+		case http.MethodPost:
+			var rule struct {
+				Configuration struct{ Target, Value string }
+			}
+			if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+				t.Errorf("Invalid create body: %v", err)
+			}
+			created = rule.Configuration.Value
+			fmt.Fprintf(w, `{"success":true,"errors":[],"messages":[],"result":
+				{"id":"rule-2","notes":"home","mode":"whitelist","configuration":{"target":%q,"value":%q}}}`,
+				rule.Configuration.Target, rule.Configuration.Value)
+		}
+	})
+	mux.HandleFunc("/client/v4/zones/zone-1/firewall/access_rules/rules/rule-1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = "rule-1"
+		}
+		fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],"result":{"id":"rule-1"}}`)
+	})
+	client := newTestClient(t, srv)
+	ctx := context.Background()
+
+	rules, err := client.FindAccessRules(ctx, "example.com", "home")
+	if err != nil {
+		t.Fatalf("FindAccessRules failed: %v", err)
+	}
+	if len(rules) != 1 || rules[0].ID != "rule-1" || rules[0].Value != "198.51.100.89" {
+		t.Fatalf("Expected only rule-1 for 198.51.100.89, got %+v", rules)
+	}
+	rule := rules[0]
+
+	replaced, err := client.ReplaceAccessRule(ctx, "example.com", rule, "whitelist", net.ParseIP("203.0.113.42"))
+	if err != nil {
+		t.Fatalf("ReplaceAccessRule failed: %v", err)
+	}
+	if created != "203.0.113.42" || replaced.Value != "203.0.113.42" {
+		t.Errorf("Expected a rule for 203.0.113.42 to be created, got %q", created)
+	}
+	if deleted != "rule-1" {
+		t.Error("Expected the old rule to be deleted")
+	}
+}
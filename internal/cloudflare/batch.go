@@ -0,0 +1,153 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	cf "github.com/cloudflare/cloudflare-go"
+)
+
+// RecordUpdate is one change for UpdateRecords: point Record at IP and apply Opts.
+type RecordUpdate struct {
+	Record *DNSRecord
+	IP     net.IP
+	Opts   RecordOptions
+}
+
+// RecordUpdateResult is the outcome of one RecordUpdate.
+type RecordUpdateResult struct {
+	// Record is the updated record, or nil if the update failed.
+	Record *DNSRecord
+	Error  error
+}
+
+// batchPatch is one entry of the "patches" list sent to the batch endpoint.
+// Fields left out keep their current values, including the record's tags.
+type batchPatch struct {
+	ID      string  `json:"id"`
+	Content string  `json:"content"`
+	Proxied *bool   `json:"proxied,omitempty"`
+	TTL     int     `json:"ttl,omitempty"`
+	Comment *string `json:"comment,omitempty"`
+}
+
+// UpdateRecords applies every update, sending the updates for each zone to
+// Cloudflare's batch DNS records endpoint as one request. Cloudflare applies
+// a batch atomically, so either all of a zone's records change or none do,
+// and when a batch is refused every one of its results carries the error.
+// Only if the batch endpoint is not available are the zone's updates sent
+// one by one instead, each result telling whether its own update succeeded.
+// Results are in the order of updates.
+func (c *Client) UpdateRecords(ctx context.Context, updates []RecordUpdate) []RecordUpdateResult {
+	results := make([]RecordUpdateResult, len(updates))
+
+	// Group the updates by zone, keeping the order zones first appear in
+	var zones []string
+	byZone := make(map[string][]int)
+	for i, u := range updates {
+		zoneID := u.Record.ZoneID
+		if _, ok := byZone[zoneID]; !ok {
+			zones = append(zones, zoneID)
+		}
+		byZone[zoneID] = append(byZone[zoneID], i)
+	}
+
+	for _, zoneID := range zones {
+		indexes := byZone[zoneID]
+		if len(indexes) > 1 {
+			records, err := c.batchUpdate(ctx, zoneID, updates, indexes)
+			if err == nil {
+				for n, i := range indexes {
+					results[i].Record = records[n]
+				}
+				continue
+			}
+			if !batchUnavailable(err) {
+				for _, i := range indexes {
+					results[i].Error = err
+				}
+				continue
+			}
+			slog.Warn("Batch DNS updates unavailable, updating records one by one", "zoneID", zoneID, "count", len(indexes), "error", err)
+		}
+
+		for _, i := range indexes {
+			u := updates[i]
+			results[i].Record, results[i].Error = c.UpdateRecord(ctx, u.Record, u.IP, u.Opts)
+		}
+	}
+	return results
+}
+
+// batchUpdate sends the updates at indexes, which all belong to zoneID, as one
+// batch request and returns the updated records in the same order.
+func (c *Client) batchUpdate(ctx context.Context, zoneID string, updates []RecordUpdate, indexes []int) ([]*DNSRecord, error) {
+	patches := make([]batchPatch, len(indexes))
+	for n, i := range indexes {
+		u := updates[i]
+		proxied, ttl := mergeOptions(u.Record, u.Opts)
+		patches[n] = batchPatch{
+			ID:      u.Record.ID,
+			Content: u.IP.String(),
+			Proxied: proxied,
+			TTL:     ttl,
+			Comment: u.Opts.Comment,
+		}
+		slog.Debug("Batching DNS record update", "hostname", u.Record.Name, "type", u.Record.Type, "id", u.Record.ID, "oldIP", u.Record.IP.String(), "newIP", u.IP.String(), "proxied", formatProxied(proxied), "ttl", ttl)
+	}
+
+	endpoint := fmt.Sprintf("/zones/%s/dns_records/batch", zoneID)
+	resp, err := c.api.Raw(ctx, http.MethodPost, endpoint, map[string]any{"patches": patches}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update DNS records in batch: %w", classify(err, ErrRecordNotFound))
+	}
+
+	var body struct {
+		Patches []cf.DNSRecord `json:"patches"`
+	}
+	if err := json.Unmarshal(resp.Result, &body); err != nil {
+		return nil, fmt.Errorf("failed to parse batch DNS update: %w", err)
+	}
+	if len(body.Patches) != len(indexes) {
+		return nil, fmt.Errorf("batch DNS update returned %d records, expected %d", len(body.Patches), len(indexes))
+	}
+
+	records := make([]*DNSRecord, len(body.Patches))
+	for n, rec := range body.Patches {
+		if records[n], err = toDNSRecord(zoneID, rec); err != nil {
+			return nil, err
+		}
+	}
+	slog.Debug("DNS records updated in batch", "zoneID", zoneID, "count", len(records))
+	return records, nil
+}
+
+// Cloudflare API error codes for a request that matched no endpoint.
+const (
+	codeNoRoute       = 7000
+	codeNoMethod      = 7001
+	codeNoObjectRoute = 7003
+)
+
+// batchUnavailable reports whether a batch request failed because the batch
+// endpoint itself is not available, rather than because of one of the
+// patches. No record was changed, so the updates can safely be sent one by
+// one. When Cloudflare rejects a patch, such as one for a deleted record, the
+// whole batch is refused and must be reported as failed, or the zone would
+// end up half updated.
+func batchUnavailable(err error) bool {
+	var apiErr *cf.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode == http.StatusMethodNotAllowed {
+		return true
+	}
+	return apiErr.StatusCode == http.StatusNotFound &&
+		(apiErr.InternalErrorCodeIs(codeNoRoute) || apiErr.InternalErrorCodeIs(codeNoMethod) || apiErr.InternalErrorCodeIs(codeNoObjectRoute))
+}
@@ -0,0 +1,114 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func batchUpdates() []RecordUpdate {
+	ip := net.ParseIP("198.51.100.9")
+	return []RecordUpdate{
+		{Record: &DNSRecord{ID: "rec-1", ZoneID: "zone-1", Type: "A", Name: "home.example.com", IP: net.ParseIP("203.0.113.7")}, IP: ip},
+		{Record: &DNSRecord{ID: "rec-2", ZoneID: "zone-1", Type: "A", Name: "vpn.example.com", IP: net.ParseIP("203.0.113.7")}, IP: ip},
+	}
+}
+
+func TestUpdateRecordsSendsOneBatchPerZone(t *testing.T) {
+	var batches int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/client/v4/zones/zone-1/dns_records/batch", func(w http.ResponseWriter, r *http.Request) {
+		batches++
+		var body struct {
+			Patches []batchPatch `json:"patches"`
+		}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil || len(body.Patches) != 2 {
+			t.Errorf("Expected 2 patches, got %s", data)
+		}
+		fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],"result":{"patches":[
+			{"id":"rec-1","type":"A","name":"home.example.com","content":"198.51.100.9"},
+			{"id":"rec-2","type":"A","name":"vpn.example.com","content":"198.51.100.9"}]}}`)
+	})
+	mux.HandleFunc("/client/v4/zones/zone-1/dns_records/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected single record request %s %s", r.Method, r.URL.Path)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	results := newTestClient(t, srv).UpdateRecords(context.Background(), batchUpdates())
+
+	if batches != 1 {
+		t.Errorf("Expected 1 batch request, got %d", batches)
+	}
+	for i, res := range results {
+		if res.Error != nil || !res.Record.IP.Equal(net.ParseIP("198.51.100.9")) {
+			t.Errorf("Update %d: unexpected result %+v", i, res)
+		}
+	}
+}
+
+func TestUpdateRecordsFallsBackToSequential(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/client/v4/zones/zone-1/dns_records/batch", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"success":false,"errors":[{"code":7003,"message":"No route for that URI"}],"messages":[],"result":null}`)
+	})
+	mux.HandleFunc("/client/v4/zones/zone-1/dns_records/rec-1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],"result":{"id":"rec-1","type":"A","name":"home.example.com","content":"198.51.100.9"}}`)
+	})
+	mux.HandleFunc("/client/v4/zones/zone-1/dns_records/rec-2", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"success":false,"errors":[{"code":9005,"message":"Content for A record is invalid."}],"messages":[],"result":null}`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	results := newTestClient(t, srv).UpdateRecords(context.Background(), batchUpdates())
+
+	if results[0].Error != nil || results[0].Record == nil {
+		t.Errorf("Expected rec-1 to be updated, got %+v", results[0])
+	}
+	if results[1].Error == nil || results[1].Record != nil {
+		t.Errorf("Expected rec-2 to fail, got %+v", results[1])
+	}
+}
+
+func TestUpdateRecordsReportsRefusedBatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"deleted record", http.StatusNotFound, `{"success":false,"errors":[{"code":81044,"message":"Record does not exist."}],"messages":[],"result":null}`},
+		{"invalid patch", http.StatusBadRequest, `{"success":false,"errors":[{"code":9005,"message":"Content for A record is invalid."}],"messages":[],"result":null}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/client/v4/zones/zone-1/dns_records/batch", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+			mux.HandleFunc("/client/v4/zones/zone-1/dns_records/", func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("Unexpected single record request %s %s", r.Method, r.URL.Path)
+			})
+			srv := httptest.NewServer(mux)
+			t.Cleanup(srv.Close)
+
+			results := newTestClient(t, srv).UpdateRecords(context.Background(), batchUpdates())
+
+			for i, res := range results {
+				if res.Error == nil || res.Record != nil {
+					t.Errorf("Expected update %d to fail with the batch, got %+v", i, res)
+				}
+			}
+		})
+	}
+}
@@ -2,130 +2,282 @@ package cloudflare
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	cf "github.com/cloudflare/cloudflare-go"
 )
 
+// DNS record types managed by the client.
+const (
+	RecordTypeA    = "A"
+	RecordTypeAAAA = "AAAA"
+)
+
 type Client struct {
-	api *cf.API
+	api     *cf.API
+	backoff *backoff
+
+	mu sync.Mutex
+	// zones maps zone names to IDs; nil until the zones have been listed.
+	zones map[string]string
+	// zoneIDs caches the zone ID for each hostname, resolved or pinned via SetZoneID.
+	zoneIDs map[string]string
+	// targetIDs caches the IDs of IP lists and load balancer pools, keyed by
+	// kind, account ID and name.
+	targetIDs map[string]string
 }
 
 type DNSRecord struct {
 	ID      string
+	ZoneID  string
+	Type    string
 	Name    string
 	IP      net.IP
 	TTL     int
 	Proxied *bool
+	Comment string
+	Tags    []string
+}
+
+// RecordOptions holds the per-record settings applied when creating or updating a record.
+type RecordOptions struct {
+	// Proxied sets the proxy (orange cloud) flag. Nil keeps the record's current
+	// setting on update and uses Cloudflare's default (DNS-only) on create.
+	Proxied *bool
+	// TTL sets the record's TTL in seconds (1 means automatic). Zero keeps the
+	// record's current TTL on update and uses DefaultTTL on create.
+	TTL int
+	// Comment replaces the record's comment. Nil keeps the current comment.
+	Comment *string
+}
+
+// DefaultTTL is the TTL used for new records when RecordOptions.TTL is unset.
+const DefaultTTL = 3600
+
+// Option customizes how the client reaches the Cloudflare API.
+type Option func(*options)
+
+type options struct {
+	baseURL    string
+	httpClient *http.Client
+	userAgent  string
+	timeout    time.Duration
+}
+
+// WithBaseURL sends API requests to baseURL instead of api.cloudflare.com,
+// e.g. an egress proxy or a local fake API. It must include the /client/v4 path.
+func WithBaseURL(baseURL string) Option {
+	return func(o *options) { o.baseURL = baseURL }
+}
+
+// WithHTTPClient sets the HTTP client used for API requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) { o.httpClient = client }
+}
+
+// WithUserAgent sets the User-Agent header sent with API requests.
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) { o.userAgent = userAgent }
+}
+
+// WithTimeout bounds each attempt of an API request. Requests that are
+// retried, e.g. after a 5xx response, may take longer in total.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.timeout = timeout }
 }
 
 // New creates a new Cloudflare client with the given API token.
-func New(apiToken string) (*Client, error) {
-	api, err := cf.NewWithAPIToken(apiToken)
+func New(apiToken string, opts ...Option) (*Client, error) {
+	return newClient(func(apiOpts []cf.Option) (*cf.API, error) {
+		return cf.NewWithAPIToken(apiToken, apiOpts...)
+	}, opts)
+}
+
+// NewWithGlobalKey creates a new Cloudflare client that authenticates with
+// the legacy Global API Key and the account email instead of an API token.
+func NewWithGlobalKey(apiKey, email string, opts ...Option) (*Client, error) {
+	return newClient(func(apiOpts []cf.Option) (*cf.API, error) {
+		return cf.New(apiKey, email, apiOpts...)
+	}, opts)
+}
+
+// newClient applies opts and creates the client with the cloudflare-go API built by newAPI.
+func newClient(newAPI func([]cf.Option) (*cf.API, error), opts []Option) (*Client, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	b := &backoff{now: time.Now}
+	api, err := newAPI(o.apiOptions(b))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Cloudflare client: %w", err)
 	}
-	return &Client{api: api}, nil
+	return &Client{api: api, backoff: b, zoneIDs: make(map[string]string), targetIDs: make(map[string]string)}, nil
 }
 
-// GetRecord fetches the A record for the given hostname.
-// It automatically extracts the zone (root domain) from the hostname.
-func (c *Client) GetRecord(ctx context.Context, hostname string) (*DNSRecord, error) {
-	zoneID, err := c.getZoneID(ctx, hostname)
-	if err != nil {
-		return nil, err
+// RateLimitedUntil returns when the current rate-limit backoff window ends,
+// or the zero time if requests may be sent now.
+func (c *Client) RateLimitedUntil() time.Time {
+	return c.backoff.blockedUntil()
+}
+
+// apiOptions translates the client options into cloudflare-go options.
+// Requests always go through a rateLimitTransport tracking b.
+func (o options) apiOptions(b *backoff) []cf.Option {
+	var apiOpts []cf.Option
+	if o.baseURL != "" {
+		apiOpts = append(apiOpts, cf.BaseURL(strings.TrimSuffix(o.baseURL, "/")))
+	}
+	if o.userAgent != "" {
+		apiOpts = append(apiOpts, cf.UserAgent(o.userAgent))
 	}
 
-	// Create ResourceContainer for the zone
-	rc := cf.ZoneIdentifier(zoneID)
+	// Copy the client so the caller's client is left untouched
+	httpClient := http.Client{}
+	if o.httpClient != nil {
+		httpClient = *o.httpClient
+	}
+	if o.timeout > 0 {
+		httpClient.Timeout = o.timeout
+	}
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	httpClient.Transport = &rateLimitTransport{base: base, backoff: b}
 
-	// List DNS records filtered by name and type A
-	records, _, err := c.api.ListDNSRecords(ctx, rc, cf.ListDNSRecordsParams{
-		Name: hostname,
-		Type: "A",
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list DNS records: %w", err)
+	return append(apiOpts, cf.HTTPClient(&httpClient))
+}
+
+// SetZoneID pins the zone ID for a hostname so it is never looked up.
+// This works with tokens that lack the Zone:Read permission.
+func (c *Client) SetZoneID(hostname, zoneID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.zoneIDs[strings.ToLower(hostname)] = zoneID
+}
+
+// RecordType returns the DNS record type that holds the given IP address:
+// "A" for IPv4 and "AAAA" for IPv6.
+func RecordType(ip net.IP) string {
+	if ip.To4() != nil {
+		return RecordTypeA
 	}
+	return RecordTypeAAAA
+}
 
-	if len(records) == 0 {
-		return nil, fmt.Errorf("A record not found for %s", hostname)
+// GetRecord fetches the record of the given type (A or AAAA) for the hostname.
+// It automatically extracts the zone (root domain) from the hostname.
+// If several records match, the oldest one is returned; use ListRecords to see all of them.
+func (c *Client) GetRecord(ctx context.Context, hostname, recordType string) (*DNSRecord, error) {
+	records, err := c.ListRecords(ctx, hostname, recordType)
+	if err != nil {
+		return nil, err
 	}
 
-	rec := records[0]
-	ip := net.ParseIP(rec.Content)
-	if ip == nil {
-		return nil, fmt.Errorf("invalid IP in DNS record: %s", rec.Content)
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s %w for %s", recordType, ErrRecordNotFound, hostname)
 	}
 
-	return &DNSRecord{
-		ID:      rec.ID,
-		Name:    rec.Name,
-		IP:      ip,
-		TTL:     rec.TTL,
-		Proxied: rec.Proxied,
-	}, nil
+	return records[0], nil
 }
 
-// UpdateRecord updates the A record with a new IP address.
-// Always enables Cloudflare proxy (orange cloud) if not already enabled.
-// Returns the updated record.
-func (c *Client) UpdateRecord(ctx context.Context, hostname string, newIP net.IP) (*DNSRecord, error) {
+// ListRecords fetches every record of the given type (A or AAAA) for the hostname.
+// Records are ordered oldest first (ties broken by ID) so callers act on them
+// deterministically regardless of API ordering.
+func (c *Client) ListRecords(ctx context.Context, hostname, recordType string) ([]*DNSRecord, error) {
 	zoneID, err := c.getZoneID(ctx, hostname)
 	if err != nil {
 		return nil, err
 	}
 
-	record, err := c.GetRecord(ctx, hostname)
+	// List DNS records filtered by name and type
+	apiRecords, _, err := c.api.ListDNSRecords(ctx, cf.ZoneIdentifier(zoneID), cf.ListDNSRecordsParams{
+		Name: hostname,
+		Type: recordType,
+	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to list DNS records: %w", classify(err, ErrZoneNotFound))
 	}
 
-	// Create ResourceContainer for the zone
-	rc := cf.ZoneIdentifier(zoneID)
+	sort.SliceStable(apiRecords, func(i, j int) bool {
+		a, b := apiRecords[i], apiRecords[j]
+		if !a.CreatedOn.Equal(b.CreatedOn) {
+			return a.CreatedOn.Before(b.CreatedOn)
+		}
+		return a.ID < b.ID
+	})
+
+	records := make([]*DNSRecord, 0, len(apiRecords))
+	for _, rec := range apiRecords {
+		record, err := toDNSRecord(zoneID, rec)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
 
-	// Always enable Cloudflare proxy (orange cloud)
-	proxied := cf.BoolPtr(true)
+// UpdateRecord points an existing record at a new IP address and applies opts.
+// Settings not given in opts keep their current values.
+// Returns the updated record.
+func (c *Client) UpdateRecord(ctx context.Context, record *DNSRecord, newIP net.IP, opts RecordOptions) (*DNSRecord, error) {
+	// Create ResourceContainer for the zone
+	rc := cf.ZoneIdentifier(record.ZoneID)
 
+	proxied, ttl := mergeOptions(record, opts)
 	updateParams := cf.UpdateDNSRecordParams{
 		ID:      record.ID,
-		Type:    "A",
-		Name:    hostname,
+		Type:    record.Type,
+		Name:    record.Name,
 		Content: newIP.String(),
-		TTL:     record.TTL,
+		TTL:     ttl,
 		Proxied: proxied,
+		Comment: opts.Comment,
+		Tags:    record.Tags, // the API clears tags that are not sent
 	}
 
-	slog.Debug("Updating DNS record", "hostname", hostname, "oldIP", record.IP.String(), "newIP", newIP.String(), "proxied", true)
+	slog.Debug("Updating DNS record", "hostname", record.Name, "type", record.Type, "id", record.ID, "oldIP", record.IP.String(), "newIP", newIP.String(), "proxied", formatProxied(proxied), "ttl", ttl)
 
 	updatedRec, err := c.api.UpdateDNSRecord(ctx, rc, updateParams)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update DNS record: %w", err)
+		return nil, fmt.Errorf("failed to update DNS record: %w", classify(err, ErrRecordNotFound))
 	}
 
-	slog.Debug("DNS record updated successfully", "hostname", hostname, "newIP", newIP.String())
+	slog.Debug("DNS record updated successfully", "hostname", record.Name, "type", record.Type, "id", record.ID, "newIP", newIP.String())
 
-	// Parse the updated record
-	updatedIP := net.ParseIP(updatedRec.Content)
-	if updatedIP == nil {
-		return nil, fmt.Errorf("invalid IP in updated DNS record: %s", updatedRec.Content)
+	return toDNSRecord(record.ZoneID, updatedRec)
+}
+
+// mergeOptions returns the proxy setting and TTL the record has once opts are
+// applied: requested values win, the others keep the record's current values.
+func mergeOptions(record *DNSRecord, opts RecordOptions) (*bool, int) {
+	proxied := record.Proxied
+	if opts.Proxied != nil {
+		proxied = opts.Proxied
 	}
 
-	return &DNSRecord{
-		ID:      updatedRec.ID,
-		Name:    updatedRec.Name,
-		IP:      updatedIP,
-		TTL:     updatedRec.TTL,
-		Proxied: updatedRec.Proxied,
-	}, nil
+	ttl := record.TTL
+	if opts.TTL != 0 {
+		ttl = opts.TTL
+	}
+	return proxied, ttl
 }
 
-// CreateRecord creates a new A record with the given IP address.
+// CreateRecord creates a new record with the given IP address and opts.
+// The record type (A or AAAA) is chosen from the address family of ip.
 // Returns the created record.
-func (c *Client) CreateRecord(ctx context.Context, hostname string, ip net.IP) (*DNSRecord, error) {
+func (c *Client) CreateRecord(ctx context.Context, hostname string, ip net.IP, opts RecordOptions) (*DNSRecord, error) {
 	zoneID, err := c.getZoneID(ctx, hostname)
 	if err != nil {
 		return nil, err
@@ -134,66 +286,155 @@ func (c *Client) CreateRecord(ctx context.Context, hostname string, ip net.IP) (
 	// Create ResourceContainer for the zone
 	rc := cf.ZoneIdentifier(zoneID)
 
+	ttl := opts.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	recordType := RecordType(ip)
 	createParams := cf.CreateDNSRecordParams{
-		Type:    "A",
+		Type:    recordType,
 		Name:    hostname,
 		Content: ip.String(),
-		TTL:     3600,             // Default TTL of 1 hour
-		Proxied: cf.BoolPtr(true), // Enable Cloudflare proxy (orange cloud)
+		TTL:     ttl,
+		Proxied: opts.Proxied,
+	}
+	if opts.Comment != nil {
+		createParams.Comment = *opts.Comment
 	}
 
-	slog.Debug("Creating DNS record", "hostname", hostname, "ip", ip.String(), "proxied", true)
+	slog.Debug("Creating DNS record", "hostname", hostname, "type", recordType, "ip", ip.String(), "proxied", formatProxied(opts.Proxied), "ttl", ttl)
 
 	rec, err := c.api.CreateDNSRecord(ctx, rc, createParams)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create DNS record: %w", err)
+		return nil, fmt.Errorf("failed to create DNS record: %w", classify(err, ErrZoneNotFound))
 	}
 
-	slog.Debug("DNS record created", "id", rec.ID, "proxied", rec.Proxied)
+	slog.Debug("DNS record created", "id", rec.ID, "type", recordType, "proxied", rec.Proxied)
 
-	createdIP := net.ParseIP(rec.Content)
-	if createdIP == nil {
-		return nil, fmt.Errorf("invalid IP in created DNS record: %s", rec.Content)
+	return toDNSRecord(zoneID, rec)
+}
+
+// DeleteRecord removes an existing record.
+func (c *Client) DeleteRecord(ctx context.Context, record *DNSRecord) error {
+	slog.Debug("Deleting DNS record", "hostname", record.Name, "type", record.Type, "id", record.ID)
+
+	if err := c.api.DeleteDNSRecord(ctx, cf.ZoneIdentifier(record.ZoneID), record.ID); err != nil {
+		return fmt.Errorf("failed to delete DNS record: %w", classify(err, ErrRecordNotFound))
+	}
+	return nil
+}
+
+// GetRecordOrCreate fetches the hostname's record for the address family of ip.
+// If the record doesn't exist, it creates one with the given IP and opts.
+// Any other error is returned as is, so a failed lookup never creates a duplicate.
+// This is useful during initial setup.
+func (c *Client) GetRecordOrCreate(ctx context.Context, hostname string, ip net.IP, opts RecordOptions) (*DNSRecord, error) {
+	record, err := c.GetRecord(ctx, hostname, RecordType(ip))
+	if !errors.Is(err, ErrRecordNotFound) {
+		return record, err
+	}
+
+	// Record doesn't exist, create it
+	return c.CreateRecord(ctx, hostname, ip, opts)
+}
+
+// formatProxied renders a proxied flag for logging.
+func formatProxied(proxied *bool) string {
+	if proxied == nil {
+		return "default"
+	}
+	if *proxied {
+		return "true"
+	}
+	return "false"
+}
+
+// toDNSRecord converts an API record from the given zone into a DNSRecord, validating its content.
+func toDNSRecord(zoneID string, rec cf.DNSRecord) (*DNSRecord, error) {
+	ip := net.ParseIP(rec.Content)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP in DNS record: %s", rec.Content)
 	}
 
 	return &DNSRecord{
 		ID:      rec.ID,
+		ZoneID:  zoneID,
+		Type:    rec.Type,
 		Name:    rec.Name,
-		IP:      createdIP,
+		IP:      ip,
 		TTL:     rec.TTL,
 		Proxied: rec.Proxied,
+		Comment: rec.Comment,
+		Tags:    rec.Tags,
 	}, nil
 }
 
-// GetRecordOrCreate fetches the A record for the given hostname.
-// If the record doesn't exist, it creates one with the given IP.
-// This is useful during initial setup.
-func (c *Client) GetRecordOrCreate(ctx context.Context, hostname string, ip net.IP) (*DNSRecord, error) {
-	record, err := c.GetRecord(ctx, hostname)
-	if err == nil {
-		return record, nil
+// getZoneID returns the zone ID for the hostname. Zones are listed once and
+// matched by the longest suffix of the hostname; the result is cached for the
+// life of the client. If no zone in an earlier listing matches, the list is
+// refreshed once in case the zone was added since.
+func (c *Client) getZoneID(ctx context.Context, hostname string) (string, error) {
+	name := strings.ToLower(strings.TrimSuffix(hostname, "."))
+	if !strings.Contains(name, ".") {
+		return "", fmt.Errorf("%w: invalid hostname %s", ErrZoneNotFound, hostname)
 	}
 
-	// Record doesn't exist, create it
-	return c.CreateRecord(ctx, hostname, ip)
-}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-// getZoneID extracts the root domain from the hostname and fetches its zone ID.
-func (c *Client) getZoneID(ctx context.Context, hostname string) (string, error) {
-	// Extract the root domain (e.g., "example.com" from "home.example.com")
-	parts := strings.Split(hostname, ".")
-	if len(parts) < 2 {
-		return "", fmt.Errorf("invalid hostname: %s", hostname)
-	}
-
-	// Try progressively shorter domain names to find the zone
-	for i := 1; i < len(parts); i++ {
-		zone := strings.Join(parts[i:], ".")
-		zoneID, err := c.api.ZoneIDByName(zone)
-		if err == nil {
-			return zoneID, nil
+	if zoneID, ok := c.zoneIDs[name]; ok {
+		return zoneID, nil
+	}
+
+	// A listing fetched by this call is already fresh, so it is not refreshed
+	fresh := false
+	if c.zones == nil {
+		if err := c.listZones(ctx); err != nil {
+			return "", err
+		}
+		fresh = true
+	}
+
+	zoneID, ok := matchZone(c.zones, name)
+	if !ok && !fresh {
+		if err := c.listZones(ctx); err != nil {
+			return "", err
 		}
+		zoneID, ok = matchZone(c.zones, name)
 	}
+	if !ok {
+		return "", fmt.Errorf("%w for %s", ErrZoneNotFound, hostname)
+	}
+	c.zoneIDs[name] = zoneID
+	return zoneID, nil
+}
 
-	return "", fmt.Errorf("zone not found for %s", hostname)
+// listZones fetches every zone the token can see. Callers must hold c.mu.
+func (c *Client) listZones(ctx context.Context) error {
+	zones, err := c.api.ListZones(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list zones: %w", classify(err, ErrZoneNotFound))
+	}
+
+	c.zones = make(map[string]string, len(zones))
+	for _, z := range zones {
+		c.zones[strings.ToLower(z.Name)] = z.ID
+	}
+	slog.Debug("Listed Cloudflare zones", "count", len(zones))
+	return nil
+}
+
+// matchZone returns the ID of the longest zone name that hostname equals or is a subdomain of.
+func matchZone(zones map[string]string, hostname string) (string, bool) {
+	for name := hostname; ; {
+		if zoneID, ok := zones[name]; ok {
+			return zoneID, true
+		}
+		_, parent, found := strings.Cut(name, ".")
+		if !found {
+			return "", false
+		}
+		name = parent
+	}
 }
@@ -0,0 +1,194 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const oneRecord = `[{"id":"rec-1","type":"A","name":"home.example.com","content":"203.0.113.7","ttl":300,"proxied":false}]`
+
+// newFakeAPI starts a fake Cloudflare API serving the example.com zone whose
+// DNS record listing returns records (a JSON array).
+func newFakeAPI(t *testing.T, records string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/client/v4/zones", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],
+			"result":[{"id":"zone-1","name":"example.com"}],
+			"result_info":{"page":1,"per_page":50,"count":1,"total_count":1,"total_pages":1}}`)
+	})
+	mux.HandleFunc("/client/v4/zones/zone-1/dns_records", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("User-Agent"); got != "ddns-test" {
+			t.Errorf("Expected User-Agent ddns-test, got %q", got)
+		}
+		fmt.Fprintf(w, `{"success":true,"errors":[],"messages":[],"result":%s,
+			"result_info":{"page":1,"per_page":100,"count":1,"total_count":1,"total_pages":1}}`, records)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMatchZoneLongestSuffix(t *testing.T) {
+	zones := map[string]string{
+		"example.com":     "zone-root",
+		"lab.example.com": "zone-lab",
+	}
+
+	tests := []struct {
+		hostname string
+		want     string
+		found    bool
+	}{
+		{"home.example.com", "zone-root", true},
+		{"example.com", "zone-root", true},
+		{"nas.lab.example.com", "zone-lab", true},
+		{"a.b.nas.lab.example.com", "zone-lab", true},
+		{"home.example.org", "", false},
+		{"com", "", false},
+	}
+
+	for _, tt := range tests {
+		got, found := matchZone(zones, tt.hostname)
+		if got != tt.want || found != tt.found {
+			t.Errorf("matchZone(%q) = %q, %v; want %q, %v", tt.hostname, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestRecordType(t *testing.T) {
+	if got := RecordType(net.ParseIP("203.0.113.1")); got != RecordTypeA {
+		t.Errorf("Expected %s for IPv4, got %s", RecordTypeA, got)
+	}
+	if got := RecordType(net.ParseIP("2001:db8::1")); got != RecordTypeAAAA {
+		t.Errorf("Expected %s for IPv6, got %s", RecordTypeAAAA, got)
+	}
+}
+
+func TestGetRecordWithBaseURL(t *testing.T) {
+	client := newTestClient(t, newFakeAPI(t, oneRecord))
+
+	rec, err := client.GetRecord(context.Background(), "home.example.com", RecordTypeA)
+	if err != nil {
+		t.Fatalf("GetRecord failed: %v", err)
+	}
+
+	if rec.ID != "rec-1" || !rec.IP.Equal(net.ParseIP("203.0.113.7")) || rec.TTL != 300 {
+		t.Errorf("Unexpected record: %+v", rec)
+	}
+}
+
+func TestListRecordsOrdersDuplicates(t *testing.T) {
+	records := `[
+		{"id":"rec-c","type":"A","name":"home.example.com","content":"203.0.113.3","created_on":"2024-03-01T00:00:00Z"},
+		{"id":"rec-b","type":"A","name":"home.example.com","content":"203.0.113.2","created_on":"2024-01-01T00:00:00Z"},
+		{"id":"rec-a","type":"A","name":"home.example.com","content":"203.0.113.1","created_on":"2024-01-01T00:00:00Z"}
+	]`
+	client := newTestClient(t, newFakeAPI(t, records))
+
+	got, err := client.ListRecords(context.Background(), "home.example.com", RecordTypeA)
+	if err != nil {
+		t.Fatalf("ListRecords failed: %v", err)
+	}
+
+	want := []string{"rec-a", "rec-b", "rec-c"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d records, got %d", len(want), len(got))
+	}
+	for i, rec := range got {
+		if rec.ID != want[i] {
+			t.Errorf("Record %d: expected %s, got %s", i, want[i], rec.ID)
+		}
+		if rec.ZoneID != "zone-1" {
+			t.Errorf("Record %d: expected zone-1, got %q", i, rec.ZoneID)
+		}
+	}
+}
+
+func TestNewWithGlobalKeySendsKeyAndEmail(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/client/v4/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Key") != "global-key" || r.Header.Get("X-Auth-Email") != "admin@example.com" {
+			t.Errorf("Expected Global API Key headers, got key %q email %q", r.Header.Get("X-Auth-Key"), r.Header.Get("X-Auth-Email"))
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("Expected no bearer token, got %q", r.Header.Get("Authorization"))
+		}
+		fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],"result":{"id":"user-1","email":"admin@example.com"}}`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	client, err := NewWithGlobalKey("global-key", "admin@example.com",
+		WithBaseURL(srv.URL+"/client/v4/"),
+		WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("NewWithGlobalKey failed: %v", err)
+	}
+
+	email, err := client.VerifyGlobalKey(context.Background())
+	if err != nil {
+		t.Fatalf("VerifyGlobalKey failed: %v", err)
+	}
+	if email != "admin@example.com" {
+		t.Errorf("Expected admin@example.com, got %q", email)
+	}
+}
+
+func TestGetZoneIDListsZonesOncePerMiss(t *testing.T) {
+	var listings int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/client/v4/zones", func(w http.ResponseWriter, r *http.Request) {
+		listings++
+		fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],
+			"result":[{"id":"zone-1","name":"example.com"}],
+			"result_info":{"page":1,"per_page":50,"count":1,"total_count":1,"total_pages":1}}`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	client := newTestClient(t, srv)
+
+	// The first miss has just listed the zones, so there is nothing to refresh
+	if _, err := client.getZoneID(context.Background(), "home.example.org"); err == nil {
+		t.Fatal("Expected no zone for home.example.org")
+	}
+	if listings != 1 {
+		t.Errorf("Expected 1 zone listing on the first miss, got %d", listings)
+	}
+
+	// A later miss refreshes the cached listing once
+	if _, err := client.getZoneID(context.Background(), "vpn.example.org"); err == nil {
+		t.Fatal("Expected no zone for vpn.example.org")
+	}
+	if listings != 2 {
+		t.Errorf("Expected 2 zone listings after a later miss, got %d", listings)
+	}
+
+	if zoneID, err := client.getZoneID(context.Background(), "home.example.com"); err != nil || zoneID != "zone-1" {
+		t.Errorf("Expected zone-1, got %q, %v", zoneID, err)
+	}
+	if listings != 2 {
+		t.Errorf("Expected a match in the cached listing, got %d listings", listings)
+	}
+}
+
+func newTestClient(t *testing.T, srv *httptest.Server) *Client {
+	t.Helper()
+
+	client, err := New("test-token",
+		WithBaseURL(srv.URL+"/client/v4/"),
+		WithHTTPClient(srv.Client()),
+		WithUserAgent("ddns-test"),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return client
+}
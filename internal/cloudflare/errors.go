@@ -0,0 +1,81 @@
+package cloudflare
+
+import (
+	"errors"
+
+	cf "github.com/cloudflare/cloudflare-go"
+)
+
+// Errors returned by the client can be told apart with errors.Is. The
+// original error stays in the chain, and its message is unchanged.
+var (
+	// ErrAuth means Cloudflare refused the request for the credentials used
+	// (HTTP 401 or 403): the token is invalid or expired, or only lacks a
+	// permission for this zone or account.
+	ErrAuth = errors.New("authentication with Cloudflare API failed")
+	// ErrUnauthorized means Cloudflare rejected the credentials themselves
+	// (HTTP 401), whatever was requested. It is also an ErrAuth.
+	ErrUnauthorized = errors.New("Cloudflare rejected the credentials")
+	// ErrZoneNotFound means no zone visible to the token holds the hostname,
+	// or a pinned zone_id does not exist.
+	ErrZoneNotFound = errors.New("zone not found")
+	// ErrRecordNotFound means the DNS record does not exist.
+	ErrRecordNotFound = errors.New("record not found")
+	// ErrTargetNotFound means a configured target other than a DNS record,
+	// such as an IP list, does not exist.
+	ErrTargetNotFound = errors.New("target not found")
+	// ErrTransient means the request failed for a reason that may go away on
+	// its own: a network error, a timeout, a 5xx response or a rate limit.
+	ErrTransient = errors.New("temporary Cloudflare API failure")
+)
+
+// IsPermanent reports whether err will not go away by retrying: the
+// credentials were rejected, or the zone, record or target does not exist.
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrAuth) || errors.Is(err, ErrZoneNotFound) ||
+		errors.Is(err, ErrRecordNotFound) || errors.Is(err, ErrTargetNotFound)
+}
+
+// kindError tags err with one of the sentinel errors above without changing its message.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.err, e.kind}
+}
+
+// classify tags an error returned by cloudflare-go with its kind. A 404 is
+// tagged with notFound, since what was not found depends on the request.
+// Rejected requests (e.g. a 400 for an invalid record) are returned as they are.
+func classify(err error, notFound error) error {
+	if err == nil || errors.Is(err, ErrRateLimited) {
+		return err
+	}
+
+	var (
+		authn      *cf.AuthenticationError
+		authz      *cf.AuthorizationError
+		missing    *cf.NotFoundError
+		badRequest *cf.RequestError
+	)
+	switch {
+	case errors.As(err, &authz):
+		// cloudflare-go reports a 401 as an AuthorizationError
+		return &kindError{kind: ErrUnauthorized, err: &kindError{kind: ErrAuth, err: err}}
+	case errors.As(err, &authn):
+		return &kindError{kind: ErrAuth, err: err}
+	case errors.As(err, &missing):
+		return &kindError{kind: notFound, err: err}
+	case errors.As(err, &badRequest):
+		return err
+	default:
+		// Network errors, timeouts and 5xx responses exhausted cloudflare-go's retries
+		return &kindError{kind: ErrTransient, err: err}
+	}
+}
@@ -0,0 +1,105 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cf "github.com/cloudflare/cloudflare-go"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"forbidden", &cf.AuthenticationError{}, ErrAuth},
+		{"unauthorized", &cf.AuthorizationError{}, ErrAuth},
+		{"not found", &cf.NotFoundError{}, ErrRecordNotFound},
+		{"network", fmt.Errorf("HTTP request failed: %w", &net.OpError{Op: "dial", Err: errors.New("refused")}), ErrTransient},
+		{"timeout", context.DeadlineExceeded, ErrTransient},
+		{"rate limited", &RateLimitError{Until: time.Now()}, ErrTransient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classify(tt.err, ErrRecordNotFound)
+			if !errors.Is(got, tt.want) {
+				t.Errorf("Expected %v to be classified as %v", got, tt.want)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("Expected the original error to be kept, got %v", got)
+			}
+		})
+	}
+
+	if err := classify(&cf.AuthorizationError{}, ErrRecordNotFound); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected a 401 to be classified as %v, got %v", ErrUnauthorized, err)
+	}
+	if err := classify(&cf.AuthenticationError{}, ErrRecordNotFound); errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected a 403 not to be classified as %v, got %v", ErrUnauthorized, err)
+	}
+
+	if err := classify(&cf.RequestError{}, ErrRecordNotFound); IsPermanent(err) || errors.Is(err, ErrTransient) {
+		t.Errorf("Expected a rejected request to stay unclassified, got %v", err)
+	}
+}
+
+func TestGetRecordOrCreateOnlyCreatesWhenMissing(t *testing.T) {
+	tests := []struct {
+		name       string
+		listStatus int
+		wantErr    error
+		wantCreate bool
+	}{
+		{"missing record", http.StatusOK, nil, true},
+		{"forbidden", http.StatusForbidden, ErrAuth, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created := false
+			mux := http.NewServeMux()
+			mux.HandleFunc("/client/v4/zones", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],
+					"result":[{"id":"zone-1","name":"example.com"}],
+					"result_info":{"page":1,"per_page":50,"count":1,"total_count":1,"total_pages":1}}`)
+			})
+			mux.HandleFunc("/client/v4/zones/zone-1/dns_records", func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost {
+					created = true
+					fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],"result":
+						{"id":"rec-new","type":"A","name":"home.example.com","content":"203.0.113.7","ttl":3600}}`)
+					return
+				}
+				w.WriteHeader(tt.listStatus)
+				if tt.listStatus != http.StatusOK {
+					fmt.Fprint(w, `{"success":false,"errors":[{"code":10000,"message":"Authentication error"}],"messages":[],"result":null}`)
+					return
+				}
+				fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],"result":[],
+					"result_info":{"page":1,"per_page":100,"count":0,"total_count":0,"total_pages":1}}`)
+			})
+			srv := httptest.NewServer(mux)
+			t.Cleanup(srv.Close)
+			client := newTestClient(t, srv)
+
+			_, err := client.GetRecordOrCreate(context.Background(), "home.example.com", net.ParseIP("203.0.113.7"), RecordOptions{})
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("GetRecordOrCreate failed: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+			}
+			if created != tt.wantCreate {
+				t.Errorf("Expected create=%v, got %v", tt.wantCreate, created)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+)
+
+// GatewayLocation is a Zero Trust Gateway DNS location.
+type GatewayLocation struct {
+	ID   string
+	Name string
+	// Networks are the location's source networks in CIDR notation.
+	Networks []string
+
+	// raw holds the location exactly as the API returned it, so an update
+	// sends every other setting back unchanged.
+	raw map[string]json.RawMessage
+}
+
+type gatewayNetwork struct {
+	Network string `json:"network"`
+}
+
+// GetGatewayLocation fetches the named Gateway DNS location in the account.
+func (c *Client) GetGatewayLocation(ctx context.Context, accountID, name string) (*GatewayLocation, error) {
+	resp, err := c.api.Raw(ctx, http.MethodGet, fmt.Sprintf("/accounts/%s/gateway/locations", accountID), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Gateway locations: %w", classify(err, ErrTargetNotFound))
+	}
+	var locations []map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Result, &locations); err != nil {
+		return nil, fmt.Errorf("failed to parse Gateway locations: %w", err)
+	}
+
+	for _, raw := range locations {
+		var locName, id string
+		_ = json.Unmarshal(raw["name"], &locName)
+		if locName != name {
+			continue
+		}
+		_ = json.Unmarshal(raw["id"], &id)
+
+		var networks []gatewayNetwork
+		_ = json.Unmarshal(raw["networks"], &networks)
+		loc := &GatewayLocation{ID: id, Name: locName, raw: raw}
+		for _, n := range networks {
+			loc.Networks = append(loc.Networks, n.Network)
+		}
+		return loc, nil
+	}
+	return nil, fmt.Errorf("%w: no Gateway location named %s in account %s", ErrTargetNotFound, name, accountID)
+}
+
+// GatewayNetworks returns the source networks the location should have for
+// ip. owned are the addresses this daemon added to the location before: their
+// /32s, other than ip's, are removed, and every other network, such as another
+// office's /32, is kept. It reports whether this differs from the location's
+// current networks.
+func (l *GatewayLocation) GatewayNetworks(ip net.IP, owned []net.IP) ([]string, bool) {
+	want := (&net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}).String()
+
+	var (
+		networks []string
+		found    bool
+		changed  bool
+	)
+	for _, n := range l.Networks {
+		switch {
+		case isHost(n, ip):
+			found = true
+		case slices.ContainsFunc(owned, func(o net.IP) bool { return isHost(n, o) }):
+			changed = true
+			continue
+		}
+		networks = append(networks, n)
+	}
+	if !found {
+		networks = append([]string{want}, networks...)
+		changed = true
+	}
+	return networks, changed
+}
+
+// isHost reports whether network is the /32 IPv4 network holding only ip.
+func isHost(network string, ip net.IP) bool {
+	addr, ipNet, err := net.ParseCIDR(network)
+	if err != nil || addr.To4() == nil {
+		return false
+	}
+	ones, _ := ipNet.Mask.Size()
+	return ones == 32 && addr.Equal(ip)
+}
+
+// SetGatewayNetworks replaces the location's source networks. Every other
+// setting of the location is sent back as it was fetched.
+func (c *Client) SetGatewayNetworks(ctx context.Context, accountID string, loc *GatewayLocation, networks []string) error {
+	body := make(map[string]json.RawMessage, len(loc.raw))
+	for k, v := range loc.raw {
+		body[k] = v
+	}
+
+	list := make([]gatewayNetwork, len(networks))
+	for i, n := range networks {
+		list[i] = gatewayNetwork{Network: n}
+	}
+	value, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	body["networks"] = value
+
+	slog.Debug("Updating Gateway location networks", "location", loc.Name, "oldNetworks", loc.Networks, "newNetworks", networks)
+
+	endpoint := fmt.Sprintf("/accounts/%s/gateway/locations/%s", accountID, loc.ID)
+	if _, err := c.api.Raw(ctx, http.MethodPut, endpoint, body, nil); err != nil {
+		return fmt.Errorf("failed to update Gateway location: %w", classify(err, ErrTargetNotFound))
+	}
+	return nil
+}
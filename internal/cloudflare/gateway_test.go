@@ -0,0 +1,41 @@
+package cloudflare
+
+import (
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestGatewayNetworks(t *testing.T) {
+	ip := net.ParseIP("203.0.113.42")
+	previous := []net.IP{net.ParseIP("198.51.100.89")}
+
+	tests := []struct {
+		name        string
+		networks    []string
+		owned       []net.IP
+		want        []string
+		wantChanged bool
+	}{
+		{"current", []string{"203.0.113.42/32"}, previous, []string{"203.0.113.42/32"}, false},
+		{"stale", []string{"198.51.100.89/32"}, previous, []string{"203.0.113.42/32"}, true},
+		{"none", nil, nil, []string{"203.0.113.42/32"}, true},
+		{"keeps ranges", []string{"192.0.2.0/24", "198.51.100.89/32"}, previous, []string{"203.0.113.42/32", "192.0.2.0/24"}, true},
+		{"current with range", []string{"192.0.2.0/24", "203.0.113.42/32"}, previous, []string{"192.0.2.0/24", "203.0.113.42/32"}, false},
+		{"keeps other office", []string{"198.51.100.89/32", "192.0.2.7/32"}, previous, []string{"203.0.113.42/32", "192.0.2.7/32"}, true},
+		{"previous unknown", []string{"192.0.2.7/32"}, nil, []string{"203.0.113.42/32", "192.0.2.7/32"}, true},
+		{"stale removed beside current", []string{"203.0.113.42/32", "198.51.100.89/32", "192.0.2.7/32"}, previous, []string{"203.0.113.42/32", "192.0.2.7/32"}, true},
+		{"several stale", []string{"198.51.100.89/32", "192.0.2.7/32", "198.51.100.90/32"}, append(previous, net.ParseIP("198.51.100.90")), []string{"203.0.113.42/32", "192.0.2.7/32"}, true},
+		{"owns current", []string{"203.0.113.42/32"}, append(previous, ip), []string{"203.0.113.42/32"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := &GatewayLocation{Networks: tt.networks}
+			got, changed := loc.GatewayNetworks(ip, tt.owned)
+			if !slices.Equal(got, tt.want) || changed != tt.wantChanged {
+				t.Errorf("GatewayNetworks() = %v, %v; want %v, %v", got, changed, tt.want, tt.wantChanged)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	cf "github.com/cloudflare/cloudflare-go"
+)
+
+// Heartbeat is the content of the TXT record the daemon refreshes to show it
+// is alive, e.g.
+//
+//	"managed-by=cloudflare-ddns host=nas version=1.4.0 last-check=2026-01-02T15:04:05Z"
+type Heartbeat struct {
+	// Host is the machine running the daemon.
+	Host string
+	// Version is the daemon's version.
+	Version string
+	// LastCheck is when the daemon last ran an update cycle.
+	LastCheck time.Time
+}
+
+// String formats the heartbeat as quoted TXT record content.
+func (h Heartbeat) String() string {
+	version := h.Version
+	if version == "" {
+		version = "unknown"
+	}
+	return fmt.Sprintf("%q", fmt.Sprintf("%s host=%s version=%s last-check=%s",
+		ownershipMarker, ownerName(h.Host), strings.Join(strings.Fields(version), "-"), h.LastCheck.UTC().Format(time.RFC3339)))
+}
+
+// WrittenBy reports whether the heartbeat names host as the machine that wrote it.
+func (h Heartbeat) WrittenBy(host string) bool {
+	return namesOwner(h.Host, host)
+}
+
+// ParseHeartbeat reads a heartbeat from TXT record content. It returns false
+// if the content was not written by cloudflare-ddns.
+func ParseHeartbeat(content string) (Heartbeat, bool) {
+	var (
+		h     Heartbeat
+		found bool
+	)
+	for _, field := range strings.Fields(strings.Trim(content, `"`)) {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "managed-by":
+			found = value == "cloudflare-ddns"
+		case "host":
+			h.Host = value
+		case "version":
+			h.Version = value
+		case "last-check":
+			h.LastCheck, _ = time.Parse(time.RFC3339, value)
+		}
+	}
+	if !found {
+		return Heartbeat{}, false
+	}
+	return h, true
+}
+
+// TXTRecord is a TXT record, such as a heartbeat.
+type TXTRecord struct {
+	ID      string
+	ZoneID  string
+	Name    string
+	Content string
+}
+
+// GetTXTRecord fetches the TXT record with the given name. If there are
+// several, the oldest one is returned. Returns an error matching
+// ErrRecordNotFound if there is none.
+func (c *Client) GetTXTRecord(ctx context.Context, name string) (*TXTRecord, error) {
+	zoneID, err := c.getZoneID(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	records, _, err := c.api.ListDNSRecords(ctx, cf.ZoneIdentifier(zoneID), cf.ListDNSRecordsParams{Name: name, Type: "TXT"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list DNS records: %w", classify(err, ErrZoneNotFound))
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("TXT %w for %s", ErrRecordNotFound, name)
+	}
+
+	oldest := records[0]
+	for _, rec := range records[1:] {
+		if rec.CreatedOn.Before(oldest.CreatedOn) {
+			oldest = rec
+		}
+	}
+	return &TXTRecord{ID: oldest.ID, ZoneID: zoneID, Name: oldest.Name, Content: oldest.Content}, nil
+}
+
+// SetTXTRecord writes content to the TXT record with the given name, creating
+// the record if it does not exist. The record gets an automatic TTL.
+func (c *Client) SetTXTRecord(ctx context.Context, name, content string) (*TXTRecord, error) {
+	existing, err := c.GetTXTRecord(ctx, name)
+	if err != nil && !errors.Is(err, ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if existing == nil {
+		zoneID, err := c.getZoneID(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		slog.Debug("Creating TXT record", "name", name, "content", content)
+		rec, err := c.api.CreateDNSRecord(ctx, cf.ZoneIdentifier(zoneID), cf.CreateDNSRecordParams{
+			Type:    "TXT",
+			Name:    name,
+			Content: content,
+			TTL:     1,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create TXT record: %w", classify(err, ErrZoneNotFound))
+		}
+		return &TXTRecord{ID: rec.ID, ZoneID: zoneID, Name: rec.Name, Content: rec.Content}, nil
+	}
+
+	slog.Debug("Updating TXT record", "name", name, "id", existing.ID, "content", content)
+	rec, err := c.api.UpdateDNSRecord(ctx, cf.ZoneIdentifier(existing.ZoneID), cf.UpdateDNSRecordParams{
+		ID:      existing.ID,
+		Type:    "TXT",
+		Name:    existing.Name,
+		Content: content,
+		TTL:     1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update TXT record: %w", classify(err, ErrRecordNotFound))
+	}
+	return &TXTRecord{ID: rec.ID, ZoneID: existing.ZoneID, Name: rec.Name, Content: rec.Content}, nil
+}
+
+// DeleteTXTRecord removes a TXT record.
+func (c *Client) DeleteTXTRecord(ctx context.Context, record *TXTRecord) error {
+	slog.Debug("Deleting TXT record", "name", record.Name, "id", record.ID)
+
+	if err := c.api.DeleteDNSRecord(ctx, cf.ZoneIdentifier(record.ZoneID), record.ID); err != nil {
+		return fmt.Errorf("failed to delete TXT record: %w", classify(err, ErrRecordNotFound))
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package cloudflare
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeartbeatRoundTrip(t *testing.T) {
+	checked := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	hb := Heartbeat{Host: "office nas", Version: "1.4.0", LastCheck: checked}
+
+	content := hb.String()
+	want := `"managed-by=cloudflare-ddns host=office-nas version=1.4.0 last-check=2026-01-02T15:04:05Z"`
+	if content != want {
+		t.Errorf("Expected %s, got %s", want, content)
+	}
+
+	got, ok := ParseHeartbeat(content)
+	if !ok {
+		t.Fatal("Expected the heartbeat to parse")
+	}
+	if got.Host != "office-nas" || got.Version != "1.4.0" || !got.LastCheck.Equal(checked) {
+		t.Errorf("Unexpected heartbeat: %+v", got)
+	}
+
+	if _, ok := ParseHeartbeat(`"v=spf1 -all"`); ok {
+		t.Error("Expected a foreign TXT record not to parse as a heartbeat")
+	}
+}
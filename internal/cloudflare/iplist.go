@@ -0,0 +1,119 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+
+	cf "github.com/cloudflare/cloudflare-go"
+)
+
+// IPListItem is an address entry of an account IP List.
+type IPListItem struct {
+	ID string
+	// Value is the address or CIDR range, as the API returns it.
+	Value   string
+	Comment string
+}
+
+// SameFamily reports whether the item holds an address of ip's family.
+func (i *IPListItem) SameFamily(ip net.IP) bool {
+	addr := net.ParseIP(i.Value)
+	if addr == nil {
+		var err error
+		if addr, _, err = net.ParseCIDR(i.Value); err != nil {
+			return false
+		}
+	}
+	return (addr.To4() != nil) == (ip.To4() != nil)
+}
+
+// IPListValue returns the entry that holds ip in an IP list: the address itself
+// for IPv4, and the /64 holding it for IPv6, since lists reject narrower IPv6 ranges.
+func IPListValue(ip net.IP) string {
+	if ip.To4() != nil {
+		return ip.String()
+	}
+	mask := net.CIDRMask(64, 128)
+	return (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+}
+
+// IPListItems fetches the items of the named IP list in the account.
+// It returns the list ID for use with ReplaceIPListItems.
+func (c *Client) IPListItems(ctx context.Context, accountID, listName string) (string, []*IPListItem, error) {
+	listID, err := c.getListID(ctx, accountID, listName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	apiItems, err := c.api.ListListItems(ctx, cf.AccountIdentifier(accountID), cf.ListListItemsParams{ID: listID})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list IP list items: %w", classify(err, ErrTargetNotFound))
+	}
+
+	items := make([]*IPListItem, 0, len(apiItems))
+	for _, item := range apiItems {
+		if item.IP == nil {
+			continue
+		}
+		items = append(items, &IPListItem{ID: item.ID, Value: *item.IP, Comment: item.Comment})
+	}
+	return listID, items, nil
+}
+
+// ReplaceIPListItems adds value to the list with the given comment and then
+// removes the old items. The new entry is added first so the list never lacks
+// the address. Each step waits for Cloudflare's bulk operation to finish.
+func (c *Client) ReplaceIPListItems(ctx context.Context, accountID, listID string, old []*IPListItem, value, comment string) error {
+	rc := cf.AccountIdentifier(accountID)
+
+	if value != "" {
+		slog.Debug("Adding IP list item", "list", listID, "value", value)
+		_, err := c.api.CreateListItem(ctx, rc, cf.ListCreateItemParams{
+			ID:   listID,
+			Item: cf.ListItemCreateRequest{IP: &value, Comment: comment},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add IP list item: %w", classify(err, ErrTargetNotFound))
+		}
+	}
+
+	if len(old) == 0 {
+		return nil
+	}
+
+	var del cf.ListItemDeleteRequest
+	for _, item := range old {
+		del.Items = append(del.Items, cf.ListItemDeleteItemRequest{ID: item.ID})
+	}
+	slog.Debug("Removing IP list items", "list", listID, "count", len(old))
+	if _, err := c.api.DeleteListItems(ctx, rc, cf.ListDeleteItemsParams{ID: listID, Items: del}); err != nil {
+		return fmt.Errorf("failed to remove IP list items: %w", classify(err, ErrTargetNotFound))
+	}
+	return nil
+}
+
+// getListID returns the ID of the named IP list, caching it for the life of the client.
+func (c *Client) getListID(ctx context.Context, accountID, listName string) (string, error) {
+	key := "list/" + accountID + "/" + listName
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if listID, ok := c.targetIDs[key]; ok {
+		return listID, nil
+	}
+
+	lists, err := c.api.ListLists(ctx, cf.AccountIdentifier(accountID), cf.ListListsParams{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list IP lists: %w", classify(err, ErrTargetNotFound))
+	}
+	for _, l := range lists {
+		if l.Name == listName && l.Kind == "ip" {
+			c.targetIDs[key] = l.ID
+			return l.ID, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no IP list named %s in account %s", ErrTargetNotFound, listName, accountID)
+}
@@ -0,0 +1,72 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestIPListValue(t *testing.T) {
+	if got := IPListValue(net.ParseIP("203.0.113.7")); got != "203.0.113.7" {
+		t.Errorf("Expected the IPv4 address itself, got %s", got)
+	}
+	if got := IPListValue(net.ParseIP("2001:db8:1:2:3:4:5:6")); got != "2001:db8:1:2::/64" {
+		t.Errorf("Expected the /64 holding the IPv6 address, got %s", got)
+	}
+}
+
+func TestIPListItemSameFamily(t *testing.T) {
+	v4, v6 := net.ParseIP("203.0.113.7"), net.ParseIP("2001:db8:1:2::7")
+	tests := []struct {
+		value  string
+		wantV4 bool
+		wantV6 bool
+	}{
+		{"198.51.100.7", true, false},
+		{"198.51.100.0/24", true, false},
+		{"2001:db8:1:2::/64", false, true},
+		{"not an address", false, false},
+	}
+	for _, tt := range tests {
+		item := &IPListItem{Value: tt.value}
+		if got := item.SameFamily(v4); got != tt.wantV4 {
+			t.Errorf("SameFamily(%s, IPv4) = %v, want %v", tt.value, got, tt.wantV4)
+		}
+		if got := item.SameFamily(v6); got != tt.wantV6 {
+			t.Errorf("SameFamily(%s, IPv6) = %v, want %v", tt.value, got, tt.wantV6)
+		}
+	}
+}
+
+func TestIPListItems(t *testing.T) {
+	srv := newFakeAPI(t, oneRecord)
+	mux := srv.Config.Handler.(*http.ServeMux)
+	mux.HandleFunc("/client/v4/accounts/acct-1/rules/lists", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],"result":[
+			{"id":"list-asn","name":"home_ips","kind":"asn"},
+			{"id":"list-1","name":"home_ips","kind":"ip"}]}`)
+	})
+	mux.HandleFunc("/client/v4/accounts/acct-1/rules/lists/list-1/items", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],"result":[
+			{"id":"item-1","ip":"203.0.113.7","comment":"managed-by=cloudflare-ddns host=nas updated=2026-01-02T15:04:05Z"},
+			{"id":"item-2","ip":"198.51.100.0/24","comment":"office"}],
+			"result_info":{"cursors":{"after":""}}}`)
+	})
+	client := newTestClient(t, srv)
+
+	listID, items, err := client.IPListItems(context.Background(), "acct-1", "home_ips")
+	if err != nil {
+		t.Fatalf("IPListItems failed: %v", err)
+	}
+	if listID != "list-1" {
+		t.Errorf("Expected the IP kind list, got %s", listID)
+	}
+	if len(items) != 2 || items[0].Value != "203.0.113.7" || items[1].Value != "198.51.100.0/24" {
+		t.Fatalf("Unexpected items: %+v", items)
+	}
+	if owner, ok := ParseOwnership(items[0].Comment); !ok || !owner.WrittenBy("nas") {
+		t.Errorf("Expected item-1 to be written by nas, got %+v", owner)
+	}
+}
@@ -0,0 +1,118 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	cf "github.com/cloudflare/cloudflare-go"
+)
+
+// PoolOrigin is one origin of an account Load Balancer pool.
+type PoolOrigin struct {
+	PoolID string
+	Pool   string
+	Name   string
+	// Address is the origin's IP address or hostname.
+	Address string
+
+	// origins holds every origin of the pool exactly as the API returned it,
+	// so an update sends the other origins back unchanged.
+	origins []map[string]json.RawMessage
+	index   int
+}
+
+// GetPoolOrigin fetches the named origin of the named Load Balancer pool in the account.
+func (c *Client) GetPoolOrigin(ctx context.Context, accountID, pool, origin string) (*PoolOrigin, error) {
+	poolID, err := c.getPoolID(ctx, accountID, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch the raw pool so fields cloudflare-go does not know about survive an update
+	resp, err := c.api.Raw(ctx, http.MethodGet, poolURI(accountID, poolID), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get load balancer pool: %w", classify(err, ErrTargetNotFound))
+	}
+	var body struct {
+		Origins []map[string]json.RawMessage `json:"origins"`
+	}
+	if err := json.Unmarshal(resp.Result, &body); err != nil {
+		return nil, fmt.Errorf("failed to parse load balancer pool: %w", err)
+	}
+
+	for i, o := range body.Origins {
+		var name, address string
+		_ = json.Unmarshal(o["name"], &name)
+		if name != origin {
+			continue
+		}
+		_ = json.Unmarshal(o["address"], &address)
+		return &PoolOrigin{
+			PoolID:  poolID,
+			Pool:    pool,
+			Name:    name,
+			Address: address,
+			origins: body.Origins,
+			index:   i,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: no origin named %s in load balancer pool %s", ErrTargetNotFound, origin, pool)
+}
+
+// SetPoolOriginAddress points the origin at address. Only the origin's address
+// changes; the pool's other origins, their weights and settings are sent back
+// as they were fetched.
+func (c *Client) SetPoolOriginAddress(ctx context.Context, accountID string, origin *PoolOrigin, address string) error {
+	value, err := json.Marshal(address)
+	if err != nil {
+		return err
+	}
+
+	origins := make([]map[string]json.RawMessage, len(origin.origins))
+	copy(origins, origin.origins)
+	updated := make(map[string]json.RawMessage, len(origins[origin.index]))
+	for k, v := range origins[origin.index] {
+		updated[k] = v
+	}
+	updated["address"] = value
+	origins[origin.index] = updated
+
+	slog.Debug("Updating load balancer origin", "pool", origin.Pool, "origin", origin.Name, "oldAddress", origin.Address, "newAddress", address)
+
+	body := map[string]any{"origins": origins}
+	if _, err := c.api.Raw(ctx, http.MethodPatch, poolURI(accountID, origin.PoolID), body, nil); err != nil {
+		return fmt.Errorf("failed to update load balancer pool: %w", classify(err, ErrTargetNotFound))
+	}
+	return nil
+}
+
+// getPoolID returns the ID of the named Load Balancer pool, caching it for the life of the client.
+func (c *Client) getPoolID(ctx context.Context, accountID, pool string) (string, error) {
+	key := "pool/" + accountID + "/" + pool
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if poolID, ok := c.targetIDs[key]; ok {
+		return poolID, nil
+	}
+
+	pools, err := c.api.ListLoadBalancerPools(ctx, cf.AccountIdentifier(accountID), cf.ListLoadBalancerPoolParams{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list load balancer pools: %w", classify(err, ErrTargetNotFound))
+	}
+	for _, p := range pools {
+		if p.Name == pool {
+			c.targetIDs[key] = p.ID
+			return p.ID, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no load balancer pool named %s in account %s", ErrTargetNotFound, pool, accountID)
+}
+
+func poolURI(accountID, poolID string) string {
+	return fmt.Sprintf("/accounts/%s/load_balancers/pools/%s", accountID, poolID)
+}
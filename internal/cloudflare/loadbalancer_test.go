@@ -0,0 +1,59 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSetPoolOriginAddressKeepsOtherOrigins(t *testing.T) {
+	var patched struct {
+		Origins []map[string]any `json:"origins"`
+	}
+
+	srv := newFakeAPI(t, oneRecord)
+	mux := srv.Config.Handler.(*http.ServeMux)
+	mux.HandleFunc("/client/v4/accounts/acct-1/load_balancers/pools", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],"result":[{"id":"pool-1","name":"residential"}]}`)
+	})
+	mux.HandleFunc("/client/v4/accounts/acct-1/load_balancers/pools/pool-1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			if err := json.NewDecoder(r.Body).Decode(&patched); err != nil {
+				t.Errorf("Invalid patch body: %v", err)
+			}
+		}
+		fmt.Fprint(w, `{"success":true,"errors":[],"messages":[],"result":{"id":"pool-1","name":"residential","origins":[
+			{"name":"datacenter","address":"192.0.2.10","enabled":true,"weight":0.8,"port":8443},
+			{"name":"home","address":"198.51.100.89","enabled":true,"weight":0.2}]}}`)
+	})
+	client := newTestClient(t, srv)
+	ctx := context.Background()
+
+	origin, err := client.GetPoolOrigin(ctx, "acct-1", "residential", "home")
+	if err != nil {
+		t.Fatalf("GetPoolOrigin failed: %v", err)
+	}
+	if origin.Address != "198.51.100.89" {
+		t.Errorf("Expected address 198.51.100.89, got %s", origin.Address)
+	}
+
+	if err := client.SetPoolOriginAddress(ctx, "acct-1", origin, "203.0.113.42"); err != nil {
+		t.Fatalf("SetPoolOriginAddress failed: %v", err)
+	}
+	if len(patched.Origins) != 2 {
+		t.Fatalf("Expected both origins to be sent, got %d", len(patched.Origins))
+	}
+	dc, home := patched.Origins[0], patched.Origins[1]
+	if dc["address"] != "192.0.2.10" || dc["weight"] != 0.8 || dc["port"] != 8443.0 {
+		t.Errorf("Expected the datacenter origin to be unchanged, got %v", dc)
+	}
+	if home["address"] != "203.0.113.42" || home["weight"] != 0.2 {
+		t.Errorf("Expected only the home address to change, got %v", home)
+	}
+
+	if _, err := client.GetPoolOrigin(ctx, "acct-1", "residential", "office"); !IsPermanent(err) {
+		t.Errorf("Expected a missing origin to be a permanent error, got %v", err)
+	}
+}
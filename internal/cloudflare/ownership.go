@@ -0,0 +1,136 @@
+package cloudflare
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ownershipMarker identifies records created or adopted by cloudflare-ddns.
+const ownershipMarker = "managed-by=cloudflare-ddns"
+
+// MaxCommentLength is the longest record comment Cloudflare accepts on the Free plan.
+const MaxCommentLength = 100
+
+// maxOwnerName caps the machine name in the marker, so that with long names
+// like EC2's ip-172-31-22-114.eu-west-1.compute.internal the marker still
+// leaves room in MaxCommentLength for text someone else left in the comment.
+const maxOwnerName = 24
+
+// Ownership is the marker cloudflare-ddns writes into the comment of the
+// records it manages, e.g.
+//
+//	managed-by=cloudflare-ddns host=nas updated=2026-01-02T15:04:05Z
+type Ownership struct {
+	// Host is the machine that last wrote the record.
+	Host string
+	// Updated is when the record was last written.
+	Updated time.Time
+}
+
+// OwnershipComment formats the ownership marker for a record written by host at t.
+func OwnershipComment(host string, t time.Time) string {
+	return fmt.Sprintf("%s host=%s updated=%s", ownershipMarker, ownerName(host), t.UTC().Format(time.RFC3339))
+}
+
+// RecordComment returns the comment for a record written by host at t whose
+// comment was existing: any text someone else left in it is kept, followed
+// by a fresh ownership marker. The text is shortened if needed so the
+// comment fits in MaxCommentLength.
+func RecordComment(existing, host string, t time.Time) string {
+	marker := OwnershipComment(host, t)
+	text := truncate(commentText(existing), MaxCommentLength-len(marker)-1)
+	if text == "" {
+		return marker
+	}
+	return text + " " + marker
+}
+
+// commentText returns comment without its ownership marker.
+func commentText(comment string) string {
+	fields := strings.Fields(comment)
+	var kept []string
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == ownershipMarker {
+			i += markerFields(fields[i+1:])
+			continue
+		}
+		kept = append(kept, fields[i])
+	}
+	return strings.Join(kept, " ")
+}
+
+// markerFields counts the host= and updated= fields at the start of fields,
+// which belong to the marker before them.
+func markerFields(fields []string) int {
+	n := 0
+	for n < len(fields) && n < 2 && (strings.HasPrefix(fields[n], "host=") || strings.HasPrefix(fields[n], "updated=")) {
+		n++
+	}
+	return n
+}
+
+// truncate shortens s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return strings.TrimSpace(s[:n])
+}
+
+// ownerName formats a machine name as it appears in the marker.
+func ownerName(host string) string {
+	host = strings.Join(strings.Fields(host), "-")
+	if host == "" {
+		return "unknown"
+	}
+	return truncate(host, maxOwnerName)
+}
+
+// WrittenBy reports whether the marker names host as the machine that wrote it.
+func (o Ownership) WrittenBy(host string) bool {
+	return namesOwner(o.Host, host)
+}
+
+// namesOwner reports whether written, a machine name read from a marker,
+// is host, including markers written before long names were shortened.
+func namesOwner(written, host string) bool {
+	return written == ownerName(host) || written == strings.Join(strings.Fields(host), "-")
+}
+
+// ParseOwnership reads the ownership marker from a record comment, which
+// may hold other text around it. It returns false if the comment carries no marker.
+func ParseOwnership(comment string) (Ownership, bool) {
+	fields := strings.Fields(comment)
+	for i, field := range fields {
+		if field != ownershipMarker {
+			continue
+		}
+
+		var o Ownership
+		for _, f := range fields[i+1 : i+1+markerFields(fields[i+1:])] {
+			key, value, _ := strings.Cut(f, "=")
+			switch key {
+			case "host":
+				o.Host = value
+			case "updated":
+				o.Updated, _ = time.Parse(time.RFC3339, value)
+			}
+		}
+		return o, true
+	}
+	return Ownership{}, false
+}
+
+// Owned reports whether the record carries the cloudflare-ddns ownership marker.
+func (r *DNSRecord) Owned() bool {
+	_, ok := ParseOwnership(r.Comment)
+	return ok
+}
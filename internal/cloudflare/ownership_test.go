@@ -0,0 +1,69 @@
+package cloudflare
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestOwnershipCommentRoundTrip(t *testing.T) {
+	at := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	comment := OwnershipComment("office nas", at)
+	if comment != "managed-by=cloudflare-ddns host=office-nas updated=2026-01-02T15:04:05Z" {
+		t.Errorf("Unexpected comment %q", comment)
+	}
+
+	owner, ok := ParseOwnership(comment)
+	if !ok {
+		t.Fatal("Expected the marker to be recognised")
+	}
+	if owner.Host != "office-nas" {
+		t.Errorf("Expected host office-nas, got %q", owner.Host)
+	}
+	if !owner.Updated.Equal(at) {
+		t.Errorf("Expected updated %s, got %s", at, owner.Updated)
+	}
+}
+
+func TestParseOwnershipWithoutMarker(t *testing.T) {
+	for _, comment := range []string{"", "created by hand", "managed-by=terraform host=ci"} {
+		if _, ok := ParseOwnership(comment); ok {
+			t.Errorf("Expected no ownership for comment %q", comment)
+		}
+	}
+
+	rec := &DNSRecord{Comment: "pointed at the VPN box by Sam"}
+	if rec.Owned() {
+		t.Error("Expected hand-made record not to be owned")
+	}
+}
+
+func TestRecordCommentKeepsTextAndFits(t *testing.T) {
+	at := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	host := "ip-172-31-22-114.eu-west-1.compute.internal"
+
+	comment := RecordComment("VPN box (Sam)", host, at)
+	if !strings.HasPrefix(comment, "VPN box (Sam) managed-by=cloudflare-ddns ") {
+		t.Errorf("Expected the existing text to be kept before the marker, got %q", comment)
+	}
+	if len(comment) > MaxCommentLength {
+		t.Errorf("Expected at most %d characters, got %d: %q", MaxCommentLength, len(comment), comment)
+	}
+	owner, ok := ParseOwnership(comment)
+	if !ok || !owner.WrittenBy(host) || !owner.Updated.Equal(at) {
+		t.Errorf("Expected the marker to name %s at %s, got %+v", host, at, owner)
+	}
+
+	// Rewriting replaces the old marker instead of piling markers up
+	again := RecordComment(comment, host, at.Add(time.Hour))
+	if strings.Count(again, ownershipMarker) != 1 || !strings.HasPrefix(again, "VPN box (Sam) ") {
+		t.Errorf("Expected one marker after the kept text, got %q", again)
+	}
+
+	long := RecordComment(strings.Repeat("é", 80), host, at)
+	if len(long) > MaxCommentLength || !utf8.ValidString(long) {
+		t.Errorf("Expected a valid comment of at most %d bytes, got %d: %q", MaxCommentLength, len(long), long)
+	}
+}
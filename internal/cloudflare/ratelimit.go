@@ -0,0 +1,101 @@
+package cloudflare
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultRetryAfter is the backoff used when a 429 response has no usable Retry-After header.
+const defaultRetryAfter = time.Minute
+
+// ErrRateLimited is matched by every RateLimitError via errors.Is, as is ErrTransient.
+var ErrRateLimited = errors.New("rate limited by Cloudflare API")
+
+// RateLimitError is returned when Cloudflare answered with HTTP 429, or when a
+// request was held back because an earlier 429's backoff window is still open.
+type RateLimitError struct {
+	// Until is when the backoff window ends and requests may be sent again.
+	Until time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v; retry after %s", ErrRateLimited, e.Until.Format(time.RFC3339))
+}
+
+func (e *RateLimitError) Unwrap() []error {
+	return []error{ErrRateLimited, ErrTransient}
+}
+
+// backoff tracks the Retry-After window shared by every request of a client.
+type backoff struct {
+	mu    sync.Mutex
+	until time.Time
+	now   func() time.Time
+}
+
+// blockedUntil returns the end of the current backoff window, or the zero time if none is open.
+func (b *backoff) blockedUntil() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.now().Before(b.until) {
+		return b.until
+	}
+	return time.Time{}
+}
+
+// extend opens (or lengthens) the backoff window to last for d.
+func (b *backoff) extend(d time.Duration) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until := b.now().Add(d); until.After(b.until) {
+		b.until = until
+	}
+	return b.until
+}
+
+// rateLimitTransport turns 429 responses into a RateLimitError and refuses to
+// send further requests until the Retry-After window has passed. Because the
+// refusal happens before the network, cloudflare-go's own retries of a 429
+// never reach the API.
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	backoff *backoff
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if until := t.backoff.blockedUntil(); !until.IsZero() {
+		return nil, &RateLimitError{Until: until}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		return resp, err
+	}
+	_ = resp.Body.Close()
+
+	until := t.backoff.extend(parseRetryAfter(resp.Header.Get("Retry-After"), t.backoff.now()))
+	slog.Warn("Cloudflare API rate limit reached", "retryAfter", until.Format(time.RFC3339))
+	return nil, &RateLimitError{Until: until}
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return defaultRetryAfter
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return defaultRetryAfter
+}
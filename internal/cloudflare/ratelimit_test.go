@@ -0,0 +1,92 @@
+package cloudflare
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countingTransport struct {
+	calls    int
+	status   int
+	header   http.Header
+	response string
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.calls++
+	return &http.Response{
+		StatusCode: ct.status,
+		Header:     ct.header,
+		Body:       io.NopCloser(strings.NewReader(ct.response)),
+		Request:    req,
+	}, nil
+}
+
+func TestRateLimitTransportBlocksUntilRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := &backoff{now: func() time.Time { return now }}
+	base := &countingTransport{
+		status: http.StatusTooManyRequests,
+		header: http.Header{"Retry-After": []string{"120"}},
+	}
+	transport := &rateLimitTransport{base: base, backoff: b}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://api.cloudflare.com/client/v4/zones", nil)
+
+	_, err := transport.RoundTrip(req)
+	var rateLimited *RateLimitError
+	if !errors.As(err, &rateLimited) {
+		t.Fatalf("Expected RateLimitError, got %v", err)
+	}
+	if !errors.Is(err, ErrRateLimited) {
+		t.Error("Expected error to match ErrRateLimited")
+	}
+	if want := now.Add(2 * time.Minute); !rateLimited.Until.Equal(want) {
+		t.Errorf("Expected backoff until %s, got %s", want, rateLimited.Until)
+	}
+
+	// Requests inside the window must not reach the API
+	base.status = http.StatusOK
+	now = now.Add(time.Minute)
+	if _, err := transport.RoundTrip(req); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected request inside the window to be refused, got %v", err)
+	}
+	if base.calls != 1 {
+		t.Errorf("Expected 1 call to the API, got %d", base.calls)
+	}
+
+	// Once the window has passed, requests go through again
+	now = now.Add(time.Minute + time.Second)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Expected request after the window to succeed, got %v", err)
+	}
+	_ = resp.Body.Close()
+	if base.calls != 2 {
+		t.Errorf("Expected 2 calls to the API, got %d", base.calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", defaultRetryAfter},
+		{"30", 30 * time.Second},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"soon", defaultRetryAfter},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s; want %s", tt.value, got, tt.want)
+		}
+	}
+}
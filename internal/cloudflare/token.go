@@ -0,0 +1,121 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	cf "github.com/cloudflare/cloudflare-go"
+)
+
+// Token permissions needed to keep DNS records in sync.
+const (
+	PermissionZoneRead = "Zone:Read"
+	PermissionDNSRead  = "DNS:Read"
+	PermissionDNSEdit  = "DNS:Edit"
+)
+
+// probeRecordID is a well-formed record ID that never exists. Updating it
+// tells apart "no DNS:Edit permission" (403) from "record not found" (404)
+// without changing anything in the zone.
+const probeRecordID = "00000000000000000000000000000000"
+
+// TokenStatus is the result of Cloudflare's token verify endpoint.
+type TokenStatus struct {
+	ID     string
+	Status string
+	// ExpiresOn is zero if the token does not expire.
+	ExpiresOn time.Time
+	// NotBefore is zero if the token is valid immediately.
+	NotBefore time.Time
+}
+
+// Active reports whether Cloudflare considers the token usable.
+func (s *TokenStatus) Active() bool {
+	return s.Status == "active"
+}
+
+// PermissionCheck reports which permissions the token lacks for one hostname's zone.
+type PermissionCheck struct {
+	Hostname string
+	ZoneID   string
+	// Missing lists the permissions the token lacks, e.g. "DNS:Edit".
+	Missing []string
+	// Error is set when a check failed for a reason other than a missing permission.
+	Error error
+}
+
+// OK reports whether every permission is present and no check failed.
+func (p PermissionCheck) OK() bool {
+	return len(p.Missing) == 0 && p.Error == nil
+}
+
+// VerifyToken asks Cloudflare whether the API token is valid and when it expires.
+func (c *Client) VerifyToken(ctx context.Context) (*TokenStatus, error) {
+	body, err := c.api.VerifyAPIToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify API token: %w", classify(err, ErrAuth))
+	}
+
+	return &TokenStatus{
+		ID:        body.ID,
+		Status:    body.Status,
+		ExpiresOn: body.ExpiresOn,
+		NotBefore: body.NotBefore,
+	}, nil
+}
+
+// VerifyGlobalKey checks the Global API Key and email by fetching the user
+// they belong to, since the token verify endpoint does not accept them.
+// It returns the user's email.
+func (c *Client) VerifyGlobalKey(ctx context.Context) (string, error) {
+	user, err := c.api.UserDetails(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify Global API Key: %w", classify(err, ErrAuth))
+	}
+	return user.Email, nil
+}
+
+// CheckPermissions checks that the token can find the hostname's zone, read
+// its DNS records and edit them. Nothing in the zone is modified.
+func (c *Client) CheckPermissions(ctx context.Context, hostname string) PermissionCheck {
+	check := PermissionCheck{Hostname: hostname}
+
+	zoneID, err := c.getZoneID(ctx, hostname)
+	if err != nil {
+		// A token without Zone:Read either fails to list zones or sees none of them
+		if !errors.Is(err, ErrAuth) && !errors.Is(err, ErrZoneNotFound) {
+			check.Error = err
+			return check
+		}
+		check.Missing = append(check.Missing, PermissionZoneRead)
+		return check
+	}
+	check.ZoneID = zoneID
+	rc := cf.ZoneIdentifier(zoneID)
+
+	if _, _, err := c.api.ListDNSRecords(ctx, rc, cf.ListDNSRecordsParams{Name: hostname}); err != nil {
+		err = classify(err, ErrZoneNotFound)
+		if !errors.Is(err, ErrAuth) {
+			check.Error = fmt.Errorf("failed to list DNS records: %w", err)
+			return check
+		}
+		check.Missing = append(check.Missing, PermissionDNSRead)
+	}
+
+	endpoint := fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, probeRecordID)
+	_, err = c.api.Raw(ctx, http.MethodPatch, endpoint, map[string]string{"comment": "cloudflare-ddns permission check"}, nil)
+	err = classify(err, ErrRecordNotFound)
+	var badRequest *cf.RequestError
+	switch {
+	case err == nil, errors.Is(err, ErrRecordNotFound), errors.As(err, &badRequest):
+		// The request got past authorization, so the token may edit records
+	case errors.Is(err, ErrAuth):
+		check.Missing = append(check.Missing, PermissionDNSEdit)
+	default:
+		check.Error = fmt.Errorf("failed to check DNS edit permission: %w", err)
+	}
+	return check
+}
@@ -0,0 +1,46 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		patchStatus int
+		wantMissing []string
+	}{
+		{"can edit", http.StatusNotFound, nil},
+		{"read only", http.StatusForbidden, []string{PermissionDNSEdit}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeAPI(t, oneRecord)
+			srv.Config.Handler.(*http.ServeMux).HandleFunc("/client/v4/zones/zone-1/dns_records/"+probeRecordID,
+				func(w http.ResponseWriter, r *http.Request) {
+					if r.Method != http.MethodPatch {
+						t.Errorf("Expected PATCH probe, got %s", r.Method)
+					}
+					w.WriteHeader(tt.patchStatus)
+					fmt.Fprint(w, `{"success":false,"errors":[{"code":10000,"message":"probe"}],"messages":[],"result":null}`)
+				})
+			client := newTestClient(t, srv)
+
+			check := client.CheckPermissions(context.Background(), "home.example.com")
+			if check.Error != nil {
+				t.Fatalf("CheckPermissions failed: %v", check.Error)
+			}
+			if check.ZoneID != "zone-1" {
+				t.Errorf("Expected zone-1, got %q", check.ZoneID)
+			}
+			if !slices.Equal(check.Missing, tt.wantMissing) {
+				t.Errorf("Expected missing %v, got %v", tt.wantMissing, check.Missing)
+			}
+		})
+	}
+}
@@ -2,14 +2,475 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
-type Config struct {
+// Policies for an address family that can no longer be detected.
+const (
+	// OnMissingKeep leaves the existing record untouched.
+	OnMissingKeep = "keep"
+	// OnMissingDelete removes the record for the missing family.
+	OnMissingDelete = "delete"
+	// OnMissingAlert reports the missing family as an update failure.
+	OnMissingAlert = "alert"
+)
+
+// Policies for a hostname that has several records of the same type.
+const (
+	// DuplicatesFail refuses to touch the records and reports an error.
+	DuplicatesFail = "fail"
+	// DuplicatesUpdateAll keeps every record and updates each of them.
+	DuplicatesUpdateAll = "update_all"
+	// DuplicatesKeepOne keeps a single record and deletes the others.
+	DuplicatesKeepOne = "keep_one"
+)
+
+// Policies for a record someone else changed since the daemon last wrote it.
+const (
+	// OnDriftOverwrite puts the current public address back.
+	OnDriftOverwrite = "overwrite"
+	// OnDriftPause leaves the record alone and stops managing it until restart.
+	OnDriftPause = "pause"
+	// OnDriftAlert leaves the record alone and reports an update failure every cycle.
+	OnDriftAlert = "alert"
+)
+
+// Ways of authenticating with the Cloudflare API.
+const (
+	// AuthToken uses a scoped API token stored in the keychain.
+	AuthToken = "token"
+	// AuthGlobalKey uses the legacy Global API Key stored in the keychain,
+	// together with the account email from Config.Email.
+	AuthGlobalKey = "global_key"
+)
+
+// Record types that can be listed in Record.Types.
+const (
+	TypeA    = "A"
+	TypeAAAA = "AAAA"
+)
+
+// ProxyMode is a record's proxied (orange-cloud) setting. In config.toml it is
+// written as `proxied = true`, `proxied = false` or `proxied = "preserve"`.
+type ProxyMode string
+
+const (
+	// ProxyOn routes traffic through the Cloudflare proxy.
+	ProxyOn ProxyMode = "true"
+	// ProxyOff makes the record DNS-only.
+	ProxyOff ProxyMode = "false"
+	// ProxyPreserve keeps whatever proxy setting the record already has.
+	ProxyPreserve ProxyMode = "preserve"
+)
+
+// UnmarshalTOML accepts a boolean or the string "preserve".
+func (p *ProxyMode) UnmarshalTOML(v any) error {
+	switch val := v.(type) {
+	case bool:
+		if val {
+			*p = ProxyOn
+		} else {
+			*p = ProxyOff
+		}
+	case string:
+		mode := ProxyMode(strings.ToLower(val))
+		if mode != ProxyOn && mode != ProxyOff && mode != ProxyPreserve {
+			return fmt.Errorf("unsupported proxied value %q (expected true, false or \"preserve\")", val)
+		}
+		*p = mode
+	default:
+		return fmt.Errorf("unsupported proxied value %v (expected true, false or \"preserve\")", v)
+	}
+	return nil
+}
+
+// MarshalTOML writes true/false as booleans and "preserve" as a string.
+func (p ProxyMode) MarshalTOML() ([]byte, error) {
+	if p == ProxyPreserve {
+		return []byte(`"preserve"`), nil
+	}
+	return []byte(p), nil
+}
+
+// Bool returns the proxied flag to send to Cloudflare, or nil for "preserve".
+func (p ProxyMode) Bool() *bool {
+	if p == ProxyPreserve {
+		return nil
+	}
+	proxied := p != ProxyOff
+	return &proxied
+}
+
+// TTL is a record's time-to-live in seconds. In config.toml it is written as
+// an integer or as "auto", which maps to Cloudflare's automatic TTL (1).
+type TTL int
+
+// TTLAuto is Cloudflare's automatic TTL.
+const TTLAuto TTL = 1
+
+// UnmarshalTOML accepts an integer number of seconds or the string "auto".
+func (t *TTL) UnmarshalTOML(v any) error {
+	switch val := v.(type) {
+	case int64:
+		*t = TTL(val)
+	case string:
+		if strings.ToLower(val) != "auto" {
+			return fmt.Errorf("unsupported ttl value %q (expected seconds or \"auto\")", val)
+		}
+		*t = TTLAuto
+	default:
+		return fmt.Errorf("unsupported ttl value %v (expected seconds or \"auto\")", v)
+	}
+	return nil
+}
+
+// MarshalTOML writes the automatic TTL as "auto" and anything else as an integer.
+func (t TTL) MarshalTOML() ([]byte, error) {
+	if t == TTLAuto {
+		return []byte(`"auto"`), nil
+	}
+	return []byte(strconv.Itoa(int(t))), nil
+}
+
+// String returns "auto" for the automatic TTL and the number of seconds otherwise.
+func (t TTL) String() string {
+	if t == TTLAuto {
+		return "auto"
+	}
+	return strconv.Itoa(int(t)) + "s"
+}
+
+// Record is one hostname managed by the daemon, configured as a [[records]] entry.
+type Record struct {
 	Hostname string `toml:"hostname"`
+	// Types lists the record types to manage ("A", "AAAA"). Defaults to A only.
+	Types []string `toml:"types,omitempty"`
+	// OnMissing decides what happens when an address family disappears:
+	// "keep", "delete" or "alert". Defaults to "alert".
+	OnMissing string `toml:"on_missing,omitempty"`
+	// Proxied is true, false or "preserve". Defaults to true.
+	Proxied ProxyMode `toml:"proxied,omitempty"`
+	// TTL is enforced on create and update when set. Unset keeps the record's
+	// current TTL and creates new records with a one-hour TTL.
+	TTL TTL `toml:"ttl,omitempty"`
+	// ZoneID pins the record's zone, skipping the zone lookup. Needed for
+	// tokens without the Zone:Read permission.
+	ZoneID string `toml:"zone_id,omitempty"`
+	// Duplicates decides what happens when several records of one type share
+	// the hostname: "fail", "update_all" or "keep_one". Defaults to "fail".
+	Duplicates string `toml:"duplicates,omitempty"`
+	// RequireOwnership refuses to modify records that lack the
+	// managed-by=cloudflare-ddns comment marker. Defaults to false.
+	RequireOwnership *bool `toml:"require_ownership,omitempty"`
+	// Heartbeat is how often the companion TXT record is refreshed, e.g. "5m".
+	// Zero disables the heartbeat.
+	Heartbeat time.Duration `toml:"heartbeat,omitempty"`
+	// HeartbeatName is the TXT record's name. Defaults to "_ddns." + Hostname.
+	HeartbeatName string `toml:"heartbeat_name,omitempty"`
+	// OnDrift decides what happens when someone else changed the record since
+	// the daemon last wrote it: "overwrite", "pause" or "alert". Defaults to "alert".
+	OnDrift string `toml:"on_drift,omitempty"`
+	// Ephemeral records exist only while `run` is running: they are created
+	// at startup and deleted on shutdown. Defaults to false.
+	Ephemeral *bool `toml:"ephemeral,omitempty"`
+	// Lease removes an ephemeral record left behind by a crash: the next run
+	// deletes it if its heartbeat is older than Lease. Requires a heartbeat;
+	// ignored for records that are not ephemeral.
+	Lease time.Duration `toml:"lease,omitempty"`
+}
+
+// APIConfig configures how the Cloudflare API is reached, under [api] in config.toml.
+type APIConfig struct {
+	// BaseURL replaces https://api.cloudflare.com/client/v4, e.g. for an egress proxy or a fake API.
+	BaseURL string `toml:"base_url,omitempty"`
+	// UserAgent replaces the default User-Agent header.
+	UserAgent string `toml:"user_agent,omitempty"`
+	// Timeout bounds each attempt of an API request, e.g. "30s"; retried
+	// requests may take longer in total. Zero means no timeout.
+	Timeout time.Duration `toml:"timeout,omitempty"`
+}
+
+// Validate checks that the API settings are usable.
+func (a APIConfig) Validate() error {
+	if a.BaseURL != "" {
+		u, err := url.Parse(a.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("api.base_url must be an http(s) URL, got %q", a.BaseURL)
+		}
+	}
+	if a.Timeout < 0 {
+		return fmt.Errorf("api.timeout cannot be negative")
+	}
+	return nil
+}
+
+type Config struct {
+	// Hostname is shorthand for a single record, managed alongside Records.
+	Hostname string `toml:"hostname,omitempty"`
+	// Types, OnMissing, Proxied, TTL, ZoneID, Duplicates, RequireOwnership,
+	// Heartbeat, OnDrift, Ephemeral and Lease apply to Hostname and are the defaults
+	// for every entry in Records.
+	Types            []string      `toml:"types,omitempty"`
+	OnMissing        string        `toml:"on_missing,omitempty"`
+	Proxied          ProxyMode     `toml:"proxied,omitempty"`
+	TTL              TTL           `toml:"ttl,omitempty"`
+	ZoneID           string        `toml:"zone_id,omitempty"`
+	Duplicates       string        `toml:"duplicates,omitempty"`
+	RequireOwnership *bool         `toml:"require_ownership,omitempty"`
+	Heartbeat        time.Duration `toml:"heartbeat,omitempty"`
+	OnDrift          string        `toml:"on_drift,omitempty"`
+	Ephemeral        *bool         `toml:"ephemeral,omitempty"`
+	Lease            time.Duration `toml:"lease,omitempty"`
+	Records          []Record      `toml:"records,omitempty"`
+	API              APIConfig     `toml:"api,omitempty"`
+	// IP chooses the providers that detect the public address.
+	IP IPConfig `toml:"ip,omitempty"`
+	// Auth is "token" (default) or "global_key".
+	Auth string `toml:"auth,omitempty"`
+	// Email is the Cloudflare account email used with the Global API Key.
+	Email string `toml:"email,omitempty"`
+	// MachineName identifies this machine in record comments. Defaults to the OS hostname.
+	MachineName string `toml:"machine_name,omitempty"`
+	// AccountID is the Cloudflare account holding account-level targets such as IP lists.
+	AccountID string `toml:"account_id,omitempty"`
+	// IPLists are account IP Lists that get an entry for the public address.
+	IPLists []IPList `toml:"ip_lists,omitempty"`
+	// AccessRules are zone-level WAF IP Access Rules pointed at the public address.
+	AccessRules []AccessRule `toml:"access_rules,omitempty"`
+	// PoolOrigins are Load Balancer pool origins pointed at the public address.
+	PoolOrigins []PoolOrigin `toml:"lb_origins,omitempty"`
+	// GatewayLocations are Zero Trust Gateway DNS locations whose source network follows the public IPv4 address.
+	GatewayLocations []GatewayLocation `toml:"gateway_locations,omitempty"`
+}
+
+// AllRecords returns every record to manage, with top-level defaults applied:
+// the top-level hostname (if set) followed by the [[records]] entries.
+func (c Config) AllRecords() []Record {
+	var records []Record
+	if c.Hostname != "" {
+		records = append(records, Record{Hostname: c.Hostname})
+	}
+	records = append(records, c.Records...)
+
+	for i := range records {
+		if len(records[i].Types) == 0 {
+			records[i].Types = c.Types
+		}
+		if records[i].OnMissing == "" {
+			records[i].OnMissing = c.OnMissing
+		}
+		if records[i].Proxied == "" {
+			records[i].Proxied = c.Proxied
+		}
+		if records[i].TTL == 0 {
+			records[i].TTL = c.TTL
+		}
+		if records[i].ZoneID == "" {
+			records[i].ZoneID = c.ZoneID
+		}
+		if records[i].Duplicates == "" {
+			records[i].Duplicates = c.Duplicates
+		}
+		if records[i].RequireOwnership == nil {
+			records[i].RequireOwnership = c.RequireOwnership
+		}
+		if records[i].Heartbeat == 0 {
+			records[i].Heartbeat = c.Heartbeat
+		}
+		if records[i].OnDrift == "" {
+			records[i].OnDrift = c.OnDrift
+		}
+		if records[i].Ephemeral == nil {
+			records[i].Ephemeral = c.Ephemeral
+		}
+		if records[i].Lease == 0 {
+			records[i].Lease = c.Lease
+		}
+	}
+	return records
+}
+
+// Machine returns the name identifying this machine in record comments:
+// MachineName if set, otherwise the OS hostname.
+func (c Config) Machine() string {
+	if c.MachineName != "" {
+		return c.MachineName
+	}
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// AuthMode returns the configured auth mode, defaulting to "token".
+func (c Config) AuthMode() string {
+	if c.Auth == "" {
+		return AuthToken
+	}
+	return c.Auth
+}
+
+// Hostnames returns the hostnames of all configured records.
+func (c Config) Hostnames() []string {
+	var hostnames []string
+	for _, r := range c.AllRecords() {
+		hostnames = append(hostnames, r.Hostname)
+	}
+	return hostnames
+}
+
+// RecordTypes returns the record types to manage, defaulting to A only.
+func (r Record) RecordTypes() []string {
+	if len(r.Types) == 0 {
+		return []string{TypeA}
+	}
+	return r.Types
+}
+
+// MissingPolicy returns the configured OnMissing policy, defaulting to "alert".
+func (r Record) MissingPolicy() string {
+	if r.OnMissing == "" {
+		return OnMissingAlert
+	}
+	return r.OnMissing
+}
+
+// DuplicatePolicy returns the configured Duplicates policy, defaulting to "fail".
+func (r Record) DuplicatePolicy() string {
+	if r.Duplicates == "" {
+		return DuplicatesFail
+	}
+	return r.Duplicates
+}
+
+// DriftPolicy returns the configured OnDrift policy, defaulting to "alert".
+func (r Record) DriftPolicy() string {
+	if r.OnDrift == "" {
+		return OnDriftAlert
+	}
+	return r.OnDrift
+}
+
+// OwnershipRequired reports whether records without the ownership marker must be left alone.
+func (r Record) OwnershipRequired() bool {
+	return r.RequireOwnership != nil && *r.RequireOwnership
+}
+
+// HeartbeatRecord returns the name of the heartbeat TXT record, or "" if the heartbeat is disabled.
+func (r Record) HeartbeatRecord() string {
+	if r.Heartbeat <= 0 {
+		return ""
+	}
+	if r.HeartbeatName != "" {
+		return r.HeartbeatName
+	}
+	return "_ddns." + r.Hostname
+}
+
+// IsEphemeral reports whether the record only exists while the daemon runs.
+func (r Record) IsEphemeral() bool {
+	return r.Ephemeral != nil && *r.Ephemeral
+}
+
+// ProxyMode returns the configured proxied setting, defaulting to true.
+func (r Record) ProxyMode() ProxyMode {
+	if r.Proxied == "" {
+		return ProxyOn
+	}
+	return r.Proxied
+}
+
+// Validate checks that the record's values are supported.
+func (r Record) Validate() error {
+	if r.Hostname == "" {
+		return fmt.Errorf("record hostname cannot be empty")
+	}
+
+	for _, t := range r.Types {
+		if t != TypeA && t != TypeAAAA {
+			return fmt.Errorf("%s: unsupported record type %q (expected %q or %q)", r.Hostname, t, TypeA, TypeAAAA)
+		}
+	}
+
+	switch r.MissingPolicy() {
+	case OnMissingKeep, OnMissingDelete, OnMissingAlert:
+	default:
+		return fmt.Errorf("%s: unsupported on_missing policy %q (expected keep, delete or alert)", r.Hostname, r.OnMissing)
+	}
+
+	switch r.DuplicatePolicy() {
+	case DuplicatesFail, DuplicatesUpdateAll, DuplicatesKeepOne:
+	default:
+		return fmt.Errorf("%s: unsupported duplicates policy %q (expected fail, update_all or keep_one)", r.Hostname, r.Duplicates)
+	}
+
+	switch r.DriftPolicy() {
+	case OnDriftOverwrite, OnDriftPause, OnDriftAlert:
+	default:
+		return fmt.Errorf("%s: unsupported on_drift policy %q (expected overwrite, pause or alert)", r.Hostname, r.OnDrift)
+	}
+
+	if r.TTL != 0 && r.TTL != TTLAuto && (r.TTL < 30 || r.TTL > 86400) {
+		return fmt.Errorf("%s: ttl must be \"auto\" or between 30 and 86400 seconds, got %d", r.Hostname, r.TTL)
+	}
+
+	if r.Heartbeat != 0 && r.Heartbeat < time.Minute {
+		return fmt.Errorf("%s: heartbeat must be at least 1m, got %s", r.Hostname, r.Heartbeat)
+	}
+
+	if r.Lease != 0 && r.IsEphemeral() {
+		switch {
+		case r.Heartbeat <= 0:
+			return fmt.Errorf("%s: lease requires a heartbeat to renew it", r.Hostname)
+		case r.Lease <= r.Heartbeat:
+			return fmt.Errorf("%s: lease (%s) must be longer than the heartbeat (%s)", r.Hostname, r.Lease, r.Heartbeat)
+		}
+	}
+	return nil
+}
+
+// Validate checks every configured record and target and rejects duplicate hostnames.
+func (c Config) Validate() error {
+	if err := c.API.Validate(); err != nil {
+		return err
+	}
+	if err := c.IP.Validate(); err != nil {
+		return err
+	}
+
+	switch c.AuthMode() {
+	case AuthToken:
+	case AuthGlobalKey:
+		if c.Email == "" {
+			return fmt.Errorf("auth = %q requires email", AuthGlobalKey)
+		}
+	default:
+		return fmt.Errorf("unsupported auth mode %q (expected %s or %s)", c.Auth, AuthToken, AuthGlobalKey)
+	}
+	if err := c.validateTargets(); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, r := range c.AllRecords() {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		name := strings.ToLower(r.Hostname)
+		if seen[name] {
+			return fmt.Errorf("hostname %s is configured more than once", r.Hostname)
+		}
+		seen[name] = true
+	}
+	return nil
 }
 
 var configPath string
@@ -24,9 +485,10 @@ func init() {
 
 // Load reads the configuration from disk. Returns empty config and nil if file doesn't exist.
 func Load() (Config, error) {
-	// Allow overriding hostname via environment variable (useful for Docker)
+	// Allow overriding hostnames via environment variable (useful for Docker).
+	// Several hostnames may be given as a comma-separated list.
 	if envHostname := os.Getenv("CLOUDFLARE_DDNS_HOSTNAME"); envHostname != "" {
-		return Config{Hostname: envHostname}, nil
+		return fromEnv(envHostname), nil
 	}
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
@@ -37,9 +499,35 @@ func Load() (Config, error) {
 	if _, err := toml.DecodeFile(configPath, &cfg); err != nil {
 		return Config{}, fmt.Errorf("failed to read config: %w", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %w", err)
+	}
 	return cfg, nil
 }
 
+// fromEnv builds a config from the comma-separated CLOUDFLARE_DDNS_HOSTNAME value.
+func fromEnv(value string) Config {
+	var cfg Config
+	for _, hostname := range strings.Split(value, ",") {
+		hostname = strings.TrimSpace(hostname)
+		if hostname == "" {
+			continue
+		}
+		if cfg.Hostname == "" {
+			cfg.Hostname = hostname
+		} else {
+			cfg.Records = append(cfg.Records, Record{Hostname: hostname})
+		}
+	}
+
+	// An account email selects Global API Key auth, with the key from CLOUDFLARE_API_KEY
+	if email := os.Getenv("CLOUDFLARE_EMAIL"); email != "" {
+		cfg.Auth = AuthGlobalKey
+		cfg.Email = email
+	}
+	return cfg
+}
+
 // Save writes the configuration to disk.
 func Save(cfg Config) error {
 	dir := filepath.Dir(configPath)
@@ -69,3 +557,9 @@ func Exists() bool {
 func GetPath() string {
 	return configPath
 }
+
+// StatePath returns the path of the file where the daemon keeps what it must
+// remember across restarts, next to the config file.
+func StatePath() string {
+	return filepath.Join(filepath.Dir(configPath), "state.json")
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestSaveAndLoad(t *testing.T) {
@@ -95,3 +96,540 @@ func TestMkdirOnSave(t *testing.T) {
 		t.Fatalf("Expected file to exist: %v", err)
 	}
 }
+
+func TestRecordTypesDefault(t *testing.T) {
+	cfg := Record{Hostname: "home.example.com"}
+
+	types := cfg.RecordTypes()
+	if len(types) != 1 || types[0] != TypeA {
+		t.Errorf("Expected default types [A], got %v", types)
+	}
+
+	if cfg.MissingPolicy() != OnMissingAlert {
+		t.Errorf("Expected default on_missing %q, got %q", OnMissingAlert, cfg.MissingPolicy())
+	}
+
+	if cfg.DuplicatePolicy() != DuplicatesFail {
+		t.Errorf("Expected default duplicates %q, got %q", DuplicatesFail, cfg.DuplicatePolicy())
+	}
+
+	if cfg.DriftPolicy() != OnDriftAlert {
+		t.Errorf("Expected default on_drift %q, got %q", OnDriftAlert, cfg.DriftPolicy())
+	}
+}
+
+func TestLoadDualStack(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	content := `hostname = "home.example.com"
+types = ["A", "AAAA"]
+on_missing = "delete"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	rec := cfg.AllRecords()[0]
+	types := rec.RecordTypes()
+	if len(types) != 2 || types[0] != TypeA || types[1] != TypeAAAA {
+		t.Errorf("Expected types [A AAAA], got %v", types)
+	}
+
+	if rec.MissingPolicy() != OnMissingDelete {
+		t.Errorf("Expected on_missing %q, got %q", OnMissingDelete, rec.MissingPolicy())
+	}
+}
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	for _, content := range []string{
+		"hostname = \"home.example.com\"\ntypes = [\"CNAME\"]\n",
+		"hostname = \"home.example.com\"\non_missing = \"ignore\"\n",
+		"hostname = \"home.example.com\"\nduplicates = \"random\"\n",
+		"hostname = \"home.example.com\"\non_drift = \"ignore\"\n",
+	} {
+		if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		if _, err := Load(); err == nil {
+			t.Errorf("Expected Load to reject config:\n%s", content)
+		}
+	}
+}
+
+func TestLoadRecordsList(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	content := `types = ["A", "AAAA"]
+
+[[records]]
+hostname = "home.example.com"
+
+[[records]]
+hostname = "vpn.example.com"
+types = ["A"]
+on_missing = "keep"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	records := cfg.AllRecords()
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	if got := records[0].RecordTypes(); len(got) != 2 {
+		t.Errorf("Expected home to inherit types [A AAAA], got %v", got)
+	}
+	if got := records[1].RecordTypes(); len(got) != 1 || got[0] != TypeA {
+		t.Errorf("Expected vpn types [A], got %v", got)
+	}
+	if records[1].MissingPolicy() != OnMissingKeep {
+		t.Errorf("Expected vpn on_missing %q, got %q", OnMissingKeep, records[1].MissingPolicy())
+	}
+}
+
+func TestLoadRejectsDuplicateHostnames(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	content := `hostname = "home.example.com"
+
+[[records]]
+hostname = "HOME.example.com"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, err := Load(); err == nil {
+		t.Error("Expected Load to reject duplicate hostnames")
+	}
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	t.Setenv("CLOUDFLARE_DDNS_HOSTNAME", "home.example.com, vpn.example.com")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	hostnames := cfg.Hostnames()
+	if len(hostnames) != 2 || hostnames[0] != "home.example.com" || hostnames[1] != "vpn.example.com" {
+		t.Errorf("Expected hostnames [home.example.com vpn.example.com], got %v", hostnames)
+	}
+}
+
+func TestProxiedSetting(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	content := `proxied = false
+
+[[records]]
+hostname = "vpn.example.com"
+
+[[records]]
+hostname = "www.example.com"
+proxied = true
+
+[[records]]
+hostname = "ssh.example.com"
+proxied = "preserve"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	records := cfg.AllRecords()
+	want := []ProxyMode{ProxyOff, ProxyOn, ProxyPreserve}
+	for i, r := range records {
+		if r.ProxyMode() != want[i] {
+			t.Errorf("%s: expected proxied %q, got %q", r.Hostname, want[i], r.ProxyMode())
+		}
+	}
+
+	if records[2].ProxyMode().Bool() != nil {
+		t.Error("Expected preserve to map to a nil proxied flag")
+	}
+
+	// Round-trip through Save to make sure the mixed bool/string form survives
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	reloaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load after Save failed: %v", err)
+	}
+	for i, r := range reloaded.AllRecords() {
+		if r.ProxyMode() != want[i] {
+			t.Errorf("%s: expected proxied %q after round-trip, got %q", r.Hostname, want[i], r.ProxyMode())
+		}
+	}
+}
+
+func TestProxiedDefaultsToOn(t *testing.T) {
+	r := Record{Hostname: "home.example.com"}
+
+	proxied := r.ProxyMode().Bool()
+	if proxied == nil || !*proxied {
+		t.Error("Expected records to be proxied by default")
+	}
+}
+
+func TestLoadRejectsInvalidProxied(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	content := "hostname = \"home.example.com\"\nproxied = \"sometimes\"\n"
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, err := Load(); err == nil {
+		t.Error("Expected Load to reject an invalid proxied value")
+	}
+}
+
+func TestTTLSetting(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	content := `ttl = 120
+
+[[records]]
+hostname = "home.example.com"
+
+[[records]]
+hostname = "vpn.example.com"
+ttl = "auto"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	records := cfg.AllRecords()
+	if records[0].TTL != 120 {
+		t.Errorf("Expected home to inherit ttl 120, got %d", records[0].TTL)
+	}
+	if records[1].TTL != TTLAuto {
+		t.Errorf("Expected vpn ttl auto, got %d", records[1].TTL)
+	}
+
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	reloaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load after Save failed: %v", err)
+	}
+	if got := reloaded.AllRecords()[1].TTL; got != TTLAuto {
+		t.Errorf("Expected ttl auto after round-trip, got %d", got)
+	}
+}
+
+func TestLoadRejectsInvalidTTL(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	for _, content := range []string{
+		"hostname = \"home.example.com\"\nttl = 5\n",
+		"hostname = \"home.example.com\"\nttl = \"fast\"\n",
+	} {
+		if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		if _, err := Load(); err == nil {
+			t.Errorf("Expected Load to reject config:\n%s", content)
+		}
+	}
+}
+
+func TestAPISettings(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	content := `hostname = "home.example.com"
+
+[api]
+base_url = "http://127.0.0.1:8080/client/v4"
+user_agent = "ddns-test"
+timeout = "15s"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.API.BaseURL != "http://127.0.0.1:8080/client/v4" {
+		t.Errorf("Unexpected base_url %q", cfg.API.BaseURL)
+	}
+	if cfg.API.UserAgent != "ddns-test" {
+		t.Errorf("Unexpected user_agent %q", cfg.API.UserAgent)
+	}
+	if cfg.API.Timeout != 15*time.Second {
+		t.Errorf("Expected timeout 15s, got %s", cfg.API.Timeout)
+	}
+}
+
+func TestLoadRejectsInvalidBaseURL(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	content := "hostname = \"home.example.com\"\n\n[api]\nbase_url = \"ftp://proxy\"\n"
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, err := Load(); err == nil {
+		t.Error("Expected Load to reject a non-HTTP base_url")
+	}
+}
+
+func TestRequireOwnershipInheritance(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	content := `require_ownership = true
+machine_name = "office-nas"
+
+[[records]]
+hostname = "home.example.com"
+
+[[records]]
+hostname = "legacy.example.com"
+require_ownership = false
+`
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	records := cfg.AllRecords()
+	if !records[0].OwnershipRequired() {
+		t.Error("Expected home to inherit require_ownership = true")
+	}
+	if records[1].OwnershipRequired() {
+		t.Error("Expected legacy to override require_ownership with false")
+	}
+	if cfg.Machine() != "office-nas" {
+		t.Errorf("Expected machine name office-nas, got %q", cfg.Machine())
+	}
+}
+
+func TestHeartbeatSetting(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	content := `heartbeat = "5m"
+
+[[records]]
+hostname = "home.example.com"
+
+[[records]]
+hostname = "lab.example.com"
+heartbeat_name = "_alive.lab.example.com"
+heartbeat = "15m"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	records := cfg.AllRecords()
+	if records[0].HeartbeatRecord() != "_ddns.home.example.com" || records[0].Heartbeat != 5*time.Minute {
+		t.Errorf("Expected home to inherit a 5m heartbeat at _ddns.home.example.com, got %s every %s", records[0].HeartbeatRecord(), records[0].Heartbeat)
+	}
+	if records[1].HeartbeatRecord() != "_alive.lab.example.com" || records[1].Heartbeat != 15*time.Minute {
+		t.Errorf("Expected lab to override the heartbeat, got %s every %s", records[1].HeartbeatRecord(), records[1].Heartbeat)
+	}
+
+	if (Record{Hostname: "home.example.com"}).HeartbeatRecord() != "" {
+		t.Error("Expected no heartbeat record when heartbeat is unset")
+	}
+
+	if err := os.WriteFile(configPath, []byte("hostname = \"home.example.com\"\nheartbeat = \"10s\"\n"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := Load(); err == nil {
+		t.Error("Expected Load to reject a heartbeat shorter than 1m")
+	}
+}
+
+func TestEphemeralLease(t *testing.T) {
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	defer func() { configPath = oldPath }()
+
+	content := `ephemeral = true
+heartbeat = "5m"
+lease = "20m"
+
+[[records]]
+hostname = "laptop.example.com"
+
+[[records]]
+hostname = "home.example.com"
+ephemeral = false
+`
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	records := cfg.AllRecords()
+	if !records[0].IsEphemeral() || records[0].Lease != 20*time.Minute {
+		t.Errorf("Expected laptop to inherit an ephemeral 20m lease, got ephemeral=%v lease=%s", records[0].IsEphemeral(), records[0].Lease)
+	}
+	if records[1].IsEphemeral() {
+		t.Error("Expected home to opt out of ephemeral")
+	}
+
+	for _, content := range []string{
+		"hostname = \"laptop.example.com\"\nephemeral = true\nlease = \"20m\"\n",
+		"hostname = \"laptop.example.com\"\nephemeral = true\nheartbeat = \"5m\"\nlease = \"5m\"\n",
+	} {
+		if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if _, err := Load(); err == nil {
+			t.Errorf("Expected Load to reject config:\n%s", content)
+		}
+	}
+}
+
+func TestAuthMode(t *testing.T) {
+	if mode := (Config{}).AuthMode(); mode != AuthToken {
+		t.Errorf("Expected default auth %q, got %q", AuthToken, mode)
+	}
+
+	cfg := Config{Hostname: "home.example.com", Auth: AuthGlobalKey}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected global_key auth without email to be rejected")
+	}
+	cfg.Email = "admin@example.com"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected global_key auth with email to be valid: %v", err)
+	}
+
+	cfg.Auth = "password"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected unknown auth mode to be rejected")
+	}
+}
+
+func TestIPProviders(t *testing.T) {
+	cfg := Config{Hostname: "home.example.com"}
+	if got := cfg.IP.ProviderNames(); len(got) != 3 || got[0] != "ipify" {
+		t.Errorf("Expected the default providers, got %v", got)
+	}
+
+	cfg.IP = IPConfig{
+		Providers: []string{"router", "icanhazip"},
+		Custom:    []CustomProvider{{Name: "router", URL: "http://192.168.1.1/status.json", JSONPath: "wan.ip"}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected custom provider config to be valid: %v", err)
+	}
+
+	cfg.IP.Providers = []string{"whatismyip"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an unknown provider to be rejected")
+	}
+
+	cfg.IP = IPConfig{Custom: []CustomProvider{{Name: "ipify", URL: "http://example.com/"}}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a custom provider shadowing a built-in to be rejected")
+	}
+
+	cfg.IP = IPConfig{
+		Providers:  []string{"wan", "ipify"},
+		Interfaces: []InterfaceProvider{{Name: "wan", Interface: "eth0", Subnets: []string{"198.51.100.0/24"}, SkipTemporary: true}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected interface provider config to be valid: %v", err)
+	}
+	cfg.IP.Interfaces[0].Subnets = []string{"198.51.100.0"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an invalid interface subnet to be rejected")
+	}
+
+	cfg.IP = IPConfig{Mode: IPModeConsensus}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected consensus mode with the default providers to be valid: %v", err)
+	}
+	if got := cfg.IP.QuorumSize(); got != 2 {
+		t.Errorf("Expected a default quorum of 2 of 3, got %d", got)
+	}
+	cfg.IP.Quorum = 4
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a quorum larger than the number of providers to be rejected")
+	}
+	cfg.IP = IPConfig{Mode: IPModeConsensus, Providers: []string{"ipify", "ipify"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a provider listed twice to be rejected, so it cannot agree with itself")
+	}
+	cfg.IP = IPConfig{Providers: []string{"ipify", "icanhazip", "ipify"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a provider listed twice to be rejected in fallback mode too")
+	}
+	cfg.IP = IPConfig{Mode: "vote"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an invalid ip mode to be rejected")
+	}
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/ip"
+)
+
+// IPConfig chooses how the public address is detected, under [ip] in config.toml.
+type IPConfig struct {
+	// Providers lists the providers to try, in order: built-in names
+	// ("ipify", "icanhazip", "ifconfig.co", "cloudflare") or the name of a Custom or
+	// Interfaces entry.
+	// Defaults to ipify, then icanhazip, then ifconfig.co.
+	Providers []string `toml:"providers,omitempty"`
+	// Custom defines providers backed by web services of your own, as [[ip.custom]] entries.
+	Custom []CustomProvider `toml:"custom,omitempty"`
+	// Interfaces defines providers that read a local network interface, as [[ip.interface]] entries.
+	Interfaces []InterfaceProvider `toml:"interface,omitempty"`
+	// Mode is IPModeFallback (default) or IPModeConsensus.
+	Mode string `toml:"mode,omitempty"`
+	// Quorum is how many providers must agree on the address in consensus
+	// mode. Defaults to a majority of Providers.
+	Quorum int `toml:"quorum,omitempty"`
+}
+
+// Values for IPConfig.Mode.
+const (
+	// IPModeFallback asks the providers in order and uses the first answer.
+	IPModeFallback = "fallback"
+	// IPModeConsensus asks every provider at once and only uses an address
+	// Quorum of them agree on.
+	IPModeConsensus = "consensus"
+)
+
+// CustomProvider is a web service that returns the public address.
+type CustomProvider struct {
+	// Name identifies the provider in Providers, logs and output.
+	Name string `toml:"name"`
+	// URL is requested for the IPv4 address; URLv6 for the IPv6 address.
+	// Leave one empty if the service only answers for one family.
+	URL   string `toml:"url,omitempty"`
+	URLv6 string `toml:"url_v6,omitempty"`
+	// Regex extracts the address from the response: the first group if it
+	// has one, otherwise the whole match.
+	Regex string `toml:"regex,omitempty"`
+	// JSONPath extracts the address from a JSON response, e.g. "wan.ip".
+	// Without Regex or JSONPath the whole response is the address.
+	JSONPath string `toml:"json_path,omitempty"`
+}
+
+// InterfaceProvider reads the public address from a network interface of this machine.
+type InterfaceProvider struct {
+	// Name identifies the provider in Providers, logs and output.
+	Name string `toml:"name"`
+	// Interface is the interface to read, e.g. "eth0". Empty means the
+	// interface holding the default route.
+	Interface string `toml:"interface,omitempty"`
+	// Subnets restricts the addresses used to these networks (CIDR notation),
+	// e.g. to pick one of several addresses. Private addresses are only used
+	// when a subnet includes them.
+	Subnets []string `toml:"subnets,omitempty"`
+	// SkipTemporary skips temporary (privacy extension) IPv6 addresses.
+	SkipTemporary bool `toml:"skip_temporary,omitempty"`
+}
+
+// ProviderNames returns the providers to try in order, applying the default.
+func (c IPConfig) ProviderNames() []string {
+	if len(c.Providers) == 0 {
+		return ip.DefaultProviders
+	}
+	return c.Providers
+}
+
+// DetectMode returns the detection mode, applying the default.
+func (c IPConfig) DetectMode() string {
+	if c.Mode == "" {
+		return IPModeFallback
+	}
+	return c.Mode
+}
+
+// QuorumSize returns how many providers must agree in consensus mode,
+// applying the default of a majority.
+func (c IPConfig) QuorumSize() int {
+	if c.Quorum == 0 {
+		return len(c.ProviderNames())/2 + 1
+	}
+	return c.Quorum
+}
+
+// Validate checks that every listed provider is built in or defined, that
+// custom providers can be built, and that the mode and quorum make sense.
+func (c IPConfig) Validate() error {
+	custom := make(map[string]bool)
+	for _, p := range c.Custom {
+		if p.Name == "" {
+			return fmt.Errorf("ip.custom: name cannot be empty")
+		}
+		if _, ok := ip.Builtin(p.Name); ok || custom[p.Name] {
+			return fmt.Errorf("ip provider %s is defined more than once", p.Name)
+		}
+		if p.URL == "" && p.URLv6 == "" {
+			return fmt.Errorf("ip provider %s: url or url_v6 is required", p.Name)
+		}
+		if _, err := ip.NewCustom(p.Name, p.URL, p.URLv6, p.Regex, p.JSONPath); err != nil {
+			return err
+		}
+		custom[p.Name] = true
+	}
+	for _, p := range c.Interfaces {
+		if p.Name == "" {
+			return fmt.Errorf("ip.interface: name cannot be empty")
+		}
+		if _, ok := ip.Builtin(p.Name); ok || custom[p.Name] {
+			return fmt.Errorf("ip provider %s is defined more than once", p.Name)
+		}
+		if _, err := ip.NewInterface(p.Name, p.Interface, p.Subnets, p.SkipTemporary); err != nil {
+			return err
+		}
+		custom[p.Name] = true
+	}
+
+	listed := make(map[string]bool)
+	for _, name := range c.Providers {
+		if _, ok := ip.Builtin(name); !ok && !custom[name] {
+			return fmt.Errorf("unknown ip provider %q (expected ipify, icanhazip, ifconfig.co, cloudflare or the name of an [[ip.custom]] or [[ip.interface]] entry)", name)
+		}
+		if listed[name] {
+			return fmt.Errorf("ip provider %s is listed more than once in ip.providers", name)
+		}
+		listed[name] = true
+	}
+
+	switch c.DetectMode() {
+	case IPModeFallback:
+	case IPModeConsensus:
+		n := len(c.ProviderNames())
+		if n < 2 {
+			return fmt.Errorf("ip mode %q needs at least 2 providers", IPModeConsensus)
+		}
+		if q := c.QuorumSize(); q < 2 || q > n {
+			return fmt.Errorf("ip quorum must be between 2 and the number of providers (%d), got %d", n, q)
+		}
+	default:
+		return fmt.Errorf("invalid ip mode %q (expected %q or %q)", c.Mode, IPModeFallback, IPModeConsensus)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IPList is an account-level IP List, configured as an [[ip_lists]] entry.
+// The daemon keeps one entry in the list pointed at the public address,
+// replacing the entry it added before whenever the address changes.
+type IPList struct {
+	// Name is the list's name, as referenced by rules (e.g. $home_ips).
+	Name string `toml:"name"`
+	// AccountID overrides the top-level account_id.
+	AccountID string `toml:"account_id,omitempty"`
+	// Type is the address family to add: "A" (IPv4, the default) or "AAAA"
+	// (IPv6, added as the /64 holding the address).
+	Type string `toml:"type,omitempty"`
+}
+
+// RecordType returns the address family to add, defaulting to A.
+func (l IPList) RecordType() string {
+	return recordType(l.Type)
+}
+
+// Actions an IP access rule can take.
+const (
+	AccessRuleWhitelist        = "whitelist"
+	AccessRuleBlock            = "block"
+	AccessRuleChallenge        = "challenge"
+	AccessRuleJSChallenge      = "js_challenge"
+	AccessRuleManagedChallenge = "managed_challenge"
+)
+
+// AccessRule is a zone-level WAF IP Access Rule, configured as an
+// [[access_rules]] entry. The rule is found by its notes and kept pointed at
+// the public address; it is created if missing.
+type AccessRule struct {
+	// Zone is the zone name, e.g. "staging.example.com".
+	Zone string `toml:"zone"`
+	// ZoneID pins the zone, skipping the zone lookup.
+	ZoneID string `toml:"zone_id,omitempty"`
+	// Notes identifies the rule and must match its notes exactly.
+	Notes string `toml:"notes"`
+	// Mode is the rule's action. Defaults to "whitelist".
+	Mode string `toml:"mode,omitempty"`
+	// Type is the address family to match: "A" (IPv4, the default) or "AAAA".
+	Type string `toml:"type,omitempty"`
+}
+
+// RecordType returns the address family to match, defaulting to A.
+func (r AccessRule) RecordType() string {
+	return recordType(r.Type)
+}
+
+// RuleMode returns the rule's action, defaulting to "whitelist".
+func (r AccessRule) RuleMode() string {
+	if r.Mode == "" {
+		return AccessRuleWhitelist
+	}
+	return r.Mode
+}
+
+// PoolOrigin is an origin inside an account Load Balancer pool, configured as
+// an [[lb_origins]] entry. Only the origin's address is kept pointed at the
+// public address; the pool's other origins and all weights are left alone.
+type PoolOrigin struct {
+	// Pool is the pool's name.
+	Pool string `toml:"pool"`
+	// Origin is the origin's name within the pool.
+	Origin string `toml:"origin"`
+	// AccountID overrides the top-level account_id.
+	AccountID string `toml:"account_id,omitempty"`
+	// Type is the address family to use: "A" (IPv4, the default) or "AAAA".
+	Type string `toml:"type,omitempty"`
+}
+
+// RecordType returns the address family to use, defaulting to A.
+func (o PoolOrigin) RecordType() string {
+	return recordType(o.Type)
+}
+
+// AllPoolOrigins returns the configured pool origins with the top-level account_id applied.
+func (c Config) AllPoolOrigins() []PoolOrigin {
+	origins := make([]PoolOrigin, len(c.PoolOrigins))
+	for i, o := range c.PoolOrigins {
+		if o.AccountID == "" {
+			o.AccountID = c.AccountID
+		}
+		origins[i] = o
+	}
+	return origins
+}
+
+// GatewayLocation is a Zero Trust Gateway DNS location, configured as a
+// [[gateway_locations]] entry. Its source network is kept at the public IPv4
+// address as a /32.
+type GatewayLocation struct {
+	// Name is the location's name.
+	Name string `toml:"name"`
+	// AccountID overrides the top-level account_id.
+	AccountID string `toml:"account_id,omitempty"`
+}
+
+// AllGatewayLocations returns the configured Gateway locations with the top-level account_id applied.
+func (c Config) AllGatewayLocations() []GatewayLocation {
+	locations := make([]GatewayLocation, len(c.GatewayLocations))
+	for i, l := range c.GatewayLocations {
+		if l.AccountID == "" {
+			l.AccountID = c.AccountID
+		}
+		locations[i] = l
+	}
+	return locations
+}
+
+// recordType defaults an unset target address family to A.
+func recordType(t string) string {
+	if t == "" {
+		return TypeA
+	}
+	return t
+}
+
+// AllIPLists returns the configured IP lists with the top-level account_id applied.
+func (c Config) AllIPLists() []IPList {
+	lists := make([]IPList, len(c.IPLists))
+	for i, l := range c.IPLists {
+		if l.AccountID == "" {
+			l.AccountID = c.AccountID
+		}
+		lists[i] = l
+	}
+	return lists
+}
+
+// TargetNames describes every configured target other than DNS records, e.g. "IP list home_ips".
+func (c Config) TargetNames() []string {
+	var names []string
+	for _, l := range c.IPLists {
+		names = append(names, "IP list "+l.Name)
+	}
+	for _, r := range c.AccessRules {
+		names = append(names, "access rule "+r.Zone)
+	}
+	for _, o := range c.PoolOrigins {
+		names = append(names, "load balancer origin "+o.Pool+"/"+o.Origin)
+	}
+	for _, l := range c.GatewayLocations {
+		names = append(names, "Gateway location "+l.Name)
+	}
+	return names
+}
+
+// Configured reports whether anything is configured to be kept in sync.
+func (c Config) Configured() bool {
+	return len(c.AllRecords()) > 0 || len(c.TargetNames()) > 0
+}
+
+// validateTargets checks every configured target other than DNS records.
+func (c Config) validateTargets() error {
+	seen := make(map[string]bool)
+	for _, l := range c.AllIPLists() {
+		if l.Name == "" {
+			return fmt.Errorf("ip_lists: name cannot be empty")
+		}
+		if l.AccountID == "" {
+			return fmt.Errorf("ip list %s: account_id is required", l.Name)
+		}
+		if t := l.RecordType(); t != TypeA && t != TypeAAAA {
+			return fmt.Errorf("ip list %s: unsupported type %q (expected %q or %q)", l.Name, l.Type, TypeA, TypeAAAA)
+		}
+		key := l.AccountID + "/" + l.Name + "/" + l.RecordType()
+		if seen[key] {
+			return fmt.Errorf("ip list %s is configured more than once", l.Name)
+		}
+		seen[key] = true
+	}
+
+	for _, r := range c.AccessRules {
+		if r.Zone == "" {
+			return fmt.Errorf("access_rules: zone cannot be empty")
+		}
+		if r.Notes == "" {
+			return fmt.Errorf("access rule %s: notes cannot be empty, they identify the rule", r.Zone)
+		}
+		if t := r.RecordType(); t != TypeA && t != TypeAAAA {
+			return fmt.Errorf("access rule %s: unsupported type %q (expected %q or %q)", r.Zone, r.Type, TypeA, TypeAAAA)
+		}
+		switch r.RuleMode() {
+		case AccessRuleWhitelist, AccessRuleBlock, AccessRuleChallenge, AccessRuleJSChallenge, AccessRuleManagedChallenge:
+		default:
+			return fmt.Errorf("access rule %s: unsupported mode %q", r.Zone, r.Mode)
+		}
+		key := "access_rule/" + strings.ToLower(r.Zone) + "/" + r.Notes
+		if seen[key] {
+			return fmt.Errorf("access rule %s with notes %q is configured more than once", r.Zone, r.Notes)
+		}
+		seen[key] = true
+	}
+
+	for _, o := range c.AllPoolOrigins() {
+		if o.Pool == "" || o.Origin == "" {
+			return fmt.Errorf("lb_origins: pool and origin cannot be empty")
+		}
+		name := o.Pool + "/" + o.Origin
+		if o.AccountID == "" {
+			return fmt.Errorf("load balancer origin %s: account_id is required", name)
+		}
+		if t := o.RecordType(); t != TypeA && t != TypeAAAA {
+			return fmt.Errorf("load balancer origin %s: unsupported type %q (expected %q or %q)", name, o.Type, TypeA, TypeAAAA)
+		}
+		key := "lb_origin/" + o.AccountID + "/" + name
+		if seen[key] {
+			return fmt.Errorf("load balancer origin %s is configured more than once", name)
+		}
+		seen[key] = true
+	}
+
+	for _, l := range c.AllGatewayLocations() {
+		if l.Name == "" {
+			return fmt.Errorf("gateway_locations: name cannot be empty")
+		}
+		if l.AccountID == "" {
+			return fmt.Errorf("Gateway location %s: account_id is required", l.Name)
+		}
+		key := "gateway_location/" + l.AccountID + "/" + l.Name
+		if seen[key] {
+			return fmt.Errorf("Gateway location %s is configured more than once", l.Name)
+		}
+		seen[key] = true
+	}
+	return nil
+}
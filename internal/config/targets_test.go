@@ -0,0 +1,144 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadContent writes content to a temporary config file and loads it.
+func loadContent(t *testing.T, content string) (Config, error) {
+	t.Helper()
+
+	oldPath := configPath
+	configPath = filepath.Join(t.TempDir(), "config.toml")
+	t.Cleanup(func() { configPath = oldPath })
+
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return Load()
+}
+
+func TestLoadIPLists(t *testing.T) {
+	cfg, err := loadContent(t, `account_id = "acct-1"
+
+[[ip_lists]]
+name = "home_ips"
+
+[[ip_lists]]
+name = "home_ips_v6"
+type = "AAAA"
+account_id = "acct-2"
+`)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	lists := cfg.AllIPLists()
+	if len(lists) != 2 {
+		t.Fatalf("Expected 2 IP lists, got %d", len(lists))
+	}
+	if lists[0].AccountID != "acct-1" || lists[0].RecordType() != TypeA {
+		t.Errorf("Expected home_ips to inherit acct-1 and default to A, got %+v", lists[0])
+	}
+	if lists[1].AccountID != "acct-2" || lists[1].RecordType() != TypeAAAA {
+		t.Errorf("Expected home_ips_v6 to keep acct-2 and AAAA, got %+v", lists[1])
+	}
+	if !cfg.Configured() {
+		t.Error("Expected a config with only IP lists to count as configured")
+	}
+	if got := strings.Join(cfg.TargetNames(), ", "); got != "IP list home_ips, IP list home_ips_v6" {
+		t.Errorf("Unexpected target names: %s", got)
+	}
+}
+
+func TestLoadRejectsInvalidIPLists(t *testing.T) {
+	tests := map[string]string{
+		"missing account": "[[ip_lists]]\nname = \"home_ips\"\n",
+		"missing name":    "account_id = \"acct-1\"\n[[ip_lists]]\ntype = \"A\"\n",
+		"bad type":        "account_id = \"acct-1\"\n[[ip_lists]]\nname = \"home_ips\"\ntype = \"MX\"\n",
+		"duplicate":       "account_id = \"acct-1\"\n[[ip_lists]]\nname = \"home_ips\"\n[[ip_lists]]\nname = \"home_ips\"\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadContent(t, content); err == nil {
+				t.Error("Expected Load to fail")
+			}
+		})
+	}
+}
+
+func TestLoadAccessRules(t *testing.T) {
+	cfg, err := loadContent(t, `[[access_rules]]
+zone = "staging.example.com"
+notes = "office"
+
+[[access_rules]]
+zone = "staging.example.org"
+notes = "office"
+mode = "block"
+`)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if got := cfg.AccessRules[0].RuleMode(); got != AccessRuleWhitelist {
+		t.Errorf("Expected mode to default to whitelist, got %s", got)
+	}
+	if got := cfg.AccessRules[1].RuleMode(); got != AccessRuleBlock {
+		t.Errorf("Expected mode block, got %s", got)
+	}
+
+	for name, content := range map[string]string{
+		"missing notes": "[[access_rules]]\nzone = \"example.com\"\n",
+		"bad mode":      "[[access_rules]]\nzone = \"example.com\"\nnotes = \"office\"\nmode = \"allow\"\n",
+	} {
+		if _, err := loadContent(t, content); err == nil {
+			t.Errorf("%s: expected Load to fail", name)
+		}
+	}
+}
+
+func TestLoadPoolOrigins(t *testing.T) {
+	cfg, err := loadContent(t, `account_id = "acct-1"
+
+[[lb_origins]]
+pool = "residential"
+origin = "home"
+`)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	origins := cfg.AllPoolOrigins()
+	if len(origins) != 1 || origins[0].AccountID != "acct-1" || origins[0].RecordType() != TypeA {
+		t.Errorf("Unexpected pool origins: %+v", origins)
+	}
+
+	if _, err := loadContent(t, "account_id = \"acct-1\"\n[[lb_origins]]\npool = \"residential\"\n"); err == nil {
+		t.Error("Expected Load to fail without an origin name")
+	}
+}
+
+func TestLoadGatewayLocations(t *testing.T) {
+	cfg, err := loadContent(t, `account_id = "acct-1"
+
+[[gateway_locations]]
+name = "Branch office"
+`)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	locations := cfg.AllGatewayLocations()
+	if len(locations) != 1 || locations[0].Name != "Branch office" || locations[0].AccountID != "acct-1" {
+		t.Errorf("Unexpected Gateway locations: %+v", locations)
+	}
+
+	if _, err := loadContent(t, "[[gateway_locations]]\nname = \"Branch office\"\n"); err == nil {
+		t.Error("Expected Load to fail without an account_id")
+	}
+}
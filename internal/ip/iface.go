@@ -0,0 +1,136 @@
+package ip
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// InterfaceProvider reads the public address from a network interface of
+// this machine, for hosts with a public address bound directly to them. No
+// request leaves the machine.
+type InterfaceProvider struct {
+	name string
+	// iface is the interface to read; empty means the one holding the default route.
+	iface string
+	// subnets, if set, are the only networks an address is taken from.
+	subnets []*net.IPNet
+	// skipTemporary skips temporary (privacy extension) IPv6 addresses.
+	skipTemporary bool
+}
+
+// NewInterface creates a provider that reads the address from the named
+// interface, or from the interface holding the default route if iface is
+// empty. Only global unicast addresses are used: private addresses are
+// skipped unless they are inside one of subnets (CIDR notation), and when
+// subnets are given, addresses outside all of them are skipped too.
+func NewInterface(name, iface string, subnets []string, skipTemporary bool) (Provider, error) {
+	p := &InterfaceProvider{name: name, iface: iface, skipTemporary: skipTemporary}
+	for _, s := range subnets {
+		_, subnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("ip provider %s: invalid subnet %q: %w", name, s, err)
+		}
+		p.subnets = append(p.subnets, subnet)
+	}
+	return p, nil
+}
+
+// Name returns the provider's name.
+func (p *InterfaceProvider) Name() string {
+	return p.name
+}
+
+// Get returns the first address of the family on the interface that passes
+// the provider's filters.
+func (p *InterfaceProvider) Get(ctx context.Context, family Family) (net.IP, error) {
+	iface, err := p.lookup(ctx, family)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list addresses of %s for %s: %w", iface.Name, p.name, err)
+	}
+
+	var temporary map[string]bool
+	if p.skipTemporary && family == IPv6 {
+		temporary, err = temporaryAddrs(iface.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find temporary addresses of %s for %s: %w", iface.Name, p.name, err)
+		}
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !family.matches(ipNet.IP) || temporary[ipNet.IP.String()] {
+			continue
+		}
+		if p.accepts(ipNet.IP) {
+			return ipNet.IP, nil
+		}
+	}
+	return nil, fmt.Errorf("%s found no usable %s address on %s", p.name, family, iface.Name)
+}
+
+// accepts reports whether ip passes the global unicast and subnet filters.
+func (p *InterfaceProvider) accepts(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() {
+		return false
+	}
+	if len(p.subnets) == 0 {
+		return !ip.IsPrivate()
+	}
+	for _, subnet := range p.subnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the provider's interface: the configured one, or the one
+// holding the default route of the family.
+func (p *InterfaceProvider) lookup(ctx context.Context, family Family) (*net.Interface, error) {
+	if p.iface != "" {
+		iface, err := net.InterfaceByName(p.iface)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find interface %s for %s: %w", p.iface, p.name, err)
+		}
+		return iface, nil
+	}
+
+	// Connecting a UDP socket sends nothing, but makes the system pick the
+	// source address it would use for the internet, i.e. the default route's
+	network, target := "udp4", "192.0.2.1:53"
+	if family == IPv6 {
+		network, target = "udp6", "[2001:db8::1]:53"
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, target)
+	if err != nil {
+		if unreachable(err) {
+			return nil, fmt.Errorf("%s found no default route: %w", p.name, family.errNoConnectivity())
+		}
+		return nil, fmt.Errorf("%s found no %s default route: %w", p.name, family, err)
+	}
+	source := conn.LocalAddr().(*net.UDPAddr).IP
+	_ = conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interfaces for %s: %w", p.name, err)
+	}
+	for i := range ifaces {
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(source) {
+				return &ifaces[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s found no interface holding %s", p.name, source)
+}
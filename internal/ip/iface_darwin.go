@@ -0,0 +1,35 @@
+package ip
+
+import (
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// temporaryAddrs returns the temporary IPv6 addresses of the interface,
+// which ifconfig marks with the "temporary" flag.
+func temporaryAddrs(iface string) (map[string]bool, error) {
+	out, err := exec.Command("ifconfig", iface).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	temporary := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "inet6" {
+			continue
+		}
+		for _, flag := range fields[2:] {
+			if flag != "temporary" {
+				continue
+			}
+			// Link-local addresses carry a zone, e.g. fe80::1%en0
+			addr, _, _ := strings.Cut(fields[1], "%")
+			if ip := net.ParseIP(addr); ip != nil {
+				temporary[ip.String()] = true
+			}
+		}
+	}
+	return temporary, nil
+}
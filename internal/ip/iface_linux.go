@@ -0,0 +1,48 @@
+package ip
+
+import (
+	"bufio"
+	"encoding/hex"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ifaTemporary is IFA_F_TEMPORARY, the flag Linux sets on privacy extension addresses.
+const ifaTemporary = 0x01
+
+// temporaryAddrs returns the temporary IPv6 addresses of the interface,
+// read from /proc/net/if_inet6.
+func temporaryAddrs(iface string) (map[string]bool, error) {
+	f, err := os.Open("/proc/net/if_inet6")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseIfInet6(f, iface)
+}
+
+// parseIfInet6 reads lines of "address index prefix scope flags name", with
+// the address and flags in hex, and returns the temporary addresses of iface.
+func parseIfInet6(r io.Reader, iface string) (map[string]bool, error) {
+	temporary := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 6 || fields[5] != iface {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[4], 16, 32)
+		if err != nil || flags&ifaTemporary == 0 {
+			continue
+		}
+		b, err := hex.DecodeString(fields[0])
+		if err != nil || len(b) != net.IPv6len {
+			continue
+		}
+		temporary[net.IP(b).String()] = true
+	}
+	return temporary, scanner.Err()
+}
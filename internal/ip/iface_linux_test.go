@@ -0,0 +1,21 @@
+package ip
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIfInet6(t *testing.T) {
+	const ifInet6 = `00000000000000000000000000000001 01 80 10 80       lo
+20010db8000000001c2b3a4d5e6f7081 02 40 00 01     eth0
+20010db8000000000000000000000010 02 40 00 00     eth0
+20010db8000000009988776655443322 03 40 00 01    wlan0
+`
+	temporary, err := parseIfInet6(strings.NewReader(ifInet6), "eth0")
+	if err != nil {
+		t.Fatalf("parseIfInet6 failed: %v", err)
+	}
+	if len(temporary) != 1 || !temporary["2001:db8::1c2b:3a4d:5e6f:7081"] {
+		t.Errorf("Expected only the temporary eth0 address, got %v", temporary)
+	}
+}
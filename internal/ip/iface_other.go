@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package ip
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// temporaryAddrs is not supported on this platform.
+func temporaryAddrs(string) (map[string]bool, error) {
+	return nil, fmt.Errorf("telling temporary IPv6 addresses apart is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,47 @@
+package ip
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestInterfaceProviderFilters(t *testing.T) {
+	p, err := NewInterface("eth0", "eth0", nil, false)
+	if err != nil {
+		t.Fatalf("NewInterface failed: %v", err)
+	}
+	iface := p.(*InterfaceProvider)
+	for addr, want := range map[string]bool{
+		"198.51.100.7": true,
+		"2001:db8::7":  true,
+		"10.0.0.7":     false,
+		"fd00::7":      false,
+		"fe80::7":      false,
+		"127.0.0.1":    false,
+	} {
+		if got := iface.accepts(net.ParseIP(addr)); got != want {
+			t.Errorf("accepts(%s) = %v, want %v", addr, got, want)
+		}
+	}
+
+	p, err = NewInterface("lan", "eth0", []string{"10.0.0.0/8"}, false)
+	if err != nil {
+		t.Fatalf("NewInterface failed: %v", err)
+	}
+	iface = p.(*InterfaceProvider)
+	if !iface.accepts(net.ParseIP("10.0.0.7")) || iface.accepts(net.ParseIP("198.51.100.7")) {
+		t.Error("Expected the subnet filter to pick only addresses inside it")
+	}
+
+	if _, err := NewInterface("bad", "", []string{"10.0.0.0"}, false); err == nil {
+		t.Error("Expected an invalid subnet to be rejected")
+	}
+}
+
+func TestInterfaceProviderMissingInterface(t *testing.T) {
+	p, _ := NewInterface("wan", "no-such-if0", nil, false)
+	if _, err := p.Get(context.Background(), IPv4); err == nil {
+		t.Error("Expected an error for an interface that does not exist")
+	}
+}
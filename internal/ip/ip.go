@@ -1,59 +1,119 @@
 package ip
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
-	"strings"
+	"syscall"
 	"time"
 )
 
+const (
+	ipv4URL = "https://api.ipify.org?format=text"
+	ipv6URL = "https://api6.ipify.org?format=text"
+)
+
+// ErrNoIPv4 and ErrNoIPv6 mean the machine cannot reach the internet over
+// that family at all, as opposed to a provider failing.
 var (
-	cachedIP net.IP
-	client   = &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	ErrNoIPv4 = errors.New("no IPv4 connectivity")
+	ErrNoIPv6 = errors.New("no IPv6 connectivity")
 )
 
-// Get fetches the current public IP from ipify and caches it.
-func Get() (net.IP, error) {
-	resp, err := client.Get("https://api.ipify.org?format=text")
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch IP from ipify: %w", err)
+// NoConnectivity reports whether err means the address family is absent
+// from this machine, rather than a detection failure worth retrying.
+func NoConnectivity(err error) bool {
+	return errors.Is(err, ErrNoIPv4) || errors.Is(err, ErrNoIPv6)
+}
+
+// errNoConnectivity returns ErrNoIPv4 or ErrNoIPv6 for the family.
+func (f Family) errNoConnectivity() error {
+	if f == IPv6 {
+		return ErrNoIPv6
 	}
-	defer resp.Body.Close()
+	return ErrNoIPv4
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ipify returned status %d", resp.StatusCode)
+var (
+	cachedIP   net.IP
+	cachedIPv6 net.IP
+	// client replaces the per-family clients when set with SetClient.
+	client *http.Client
+	// familyClients only connect over their family's network, so a provider
+	// sees the family we ask about rather than whichever one Go dialed first.
+	familyClients = map[Family]*http.Client{
+		IPv4: newFamilyClient(IPv4),
+		IPv6: newFamilyClient(IPv6),
 	}
+)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read ipify response: %w", err)
+// newFamilyClient creates a client whose connections only use the family's network.
+func newFamilyClient(family Family) *http.Client {
+	network := "tcp4"
+	if family == IPv6 {
+		network = "tcp6"
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil && unreachable(err) {
+			return nil, fmt.Errorf("%w: %w", family.errNoConnectivity(), err)
+		}
+		return conn, err
 	}
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+// unreachable reports whether a dial failed because this machine has no
+// route or no address for the network, rather than because of the server.
+func unreachable(err error) bool {
+	return errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.EADDRNOTAVAIL) ||
+		errors.Is(err, syscall.EAFNOSUPPORT)
+}
 
-	ipStr := strings.TrimSpace(string(body))
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		return nil, fmt.Errorf("invalid IP returned from ipify: %s", ipStr)
+// clientFor returns the client to request the family's address with.
+func clientFor(family Family) *http.Client {
+	if client != nil {
+		return client
 	}
+	return familyClients[family]
+}
 
-	cachedIP = ip
-	return ip, nil
+// Get fetches the current public IPv4 address from ipify and caches it.
+func Get() (net.IP, error) {
+	result, err := Detect(context.Background(), []Provider{ipify}, IPv4)
+	return result.IP, err
 }
 
-// GetCached returns the cached IP without making a network call.
+// GetIPv6 fetches the current public IPv6 address from ipify and caches it.
+// It returns ErrNoIPv6 when the machine has no IPv6 connectivity.
+func GetIPv6() (net.IP, error) {
+	result, err := Detect(context.Background(), []Provider{ipify}, IPv6)
+	return result.IP, err
+}
+
+// GetCached returns the cached IPv4 address without making a network call.
 func GetCached() net.IP {
 	return cachedIP
 }
 
+// GetCachedIPv6 returns the cached IPv6 address without making a network call.
+func GetCachedIPv6() net.IP {
+	return cachedIPv6
+}
+
 // IsCached returns true if we have a cached IP.
 func IsCached() bool {
 	return cachedIP != nil
 }
 
 // SetClient allows injection of a custom HTTP client (useful for testing).
+// It is used for both families, so the address family is no longer forced.
 func SetClient(c *http.Client) {
 	client = c
 }
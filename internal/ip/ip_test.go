@@ -1,8 +1,13 @@
 package ip
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 )
@@ -164,3 +169,109 @@ func TestIPWithWhitespace(t *testing.T) {
 		t.Errorf("Expected IP '10.20.30.40', got '%s'", ip.String())
 	}
 }
+
+func TestGetIPv6(t *testing.T) {
+	cachedIPv6 = nil
+
+	mockClient := &http.Client{
+		Transport: &mockTransport{
+			response: &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader("2001:db8::1\n")),
+			},
+		},
+	}
+
+	oldClient := client
+	client = mockClient
+	defer func() { client = oldClient }()
+
+	ip, err := GetIPv6()
+	if err != nil {
+		t.Fatalf("GetIPv6() failed: %v", err)
+	}
+
+	if ip.String() != "2001:db8::1" {
+		t.Errorf("Expected IP '2001:db8::1', got '%s'", ip.String())
+	}
+
+	if !GetCachedIPv6().Equal(ip) {
+		t.Errorf("Expected cached IPv6 to equal fetched IP")
+	}
+}
+
+func TestGetRejectsWrongFamily(t *testing.T) {
+	cachedIP = nil
+	cachedIPv6 = nil
+
+	oldClient := client
+	defer func() { client = oldClient }()
+
+	client = &http.Client{
+		Transport: &mockTransport{
+			response: &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader("2001:db8::1")),
+			},
+		},
+	}
+	if _, err := Get(); err == nil {
+		t.Error("Expected error when Get() receives an IPv6 address")
+	}
+
+	client = &http.Client{
+		Transport: &mockTransport{
+			response: &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader("192.168.1.1")),
+			},
+		},
+	}
+	if _, err := GetIPv6(); err == nil {
+		t.Error("Expected error when GetIPv6() receives an IPv4 address")
+	}
+}
+
+func TestFamilyClientsForceNetwork(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	resp, err := clientFor(IPv4).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the IPv4 client to reach %s: %v", server.URL, err)
+	}
+	resp.Body.Close()
+
+	if resp, err := clientFor(IPv6).Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Errorf("Expected the IPv6 client not to connect to IPv4-only %s", server.URL)
+	}
+}
+
+// staticProvider answers every request with the same address or error.
+type staticProvider struct {
+	ip  net.IP
+	err error
+}
+
+func (p staticProvider) Name() string { return "static" }
+
+func (p staticProvider) Get(context.Context, Family) (net.IP, error) { return p.ip, p.err }
+
+func TestDetectReportsNoIPv6(t *testing.T) {
+	noRoute := staticProvider{err: fmt.Errorf("failed to fetch IP from static: %w", ErrNoIPv6)}
+	v4Only := staticProvider{err: fmt.Errorf("static does not support IPv6: %w", errUnsupported)}
+
+	_, err := Detect(context.Background(), []Provider{v4Only, noRoute}, IPv6)
+	if err != ErrNoIPv6 {
+		t.Errorf("Expected ErrNoIPv6 when no provider can connect over IPv6, got %v", err)
+	}
+
+	failing := staticProvider{err: errors.New("static returned status 503")}
+	_, err = Detect(context.Background(), []Provider{noRoute, failing}, IPv6)
+	if err == ErrNoIPv6 || err == nil {
+		t.Errorf("Expected a provider failure to be reported as is, got %v", err)
+	}
+}
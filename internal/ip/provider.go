@@ -0,0 +1,358 @@
+package ip
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Family is an IP address family.
+type Family int
+
+// Address families a provider can be asked for.
+const (
+	IPv4 Family = 4
+	IPv6 Family = 6
+)
+
+func (f Family) String() string {
+	if f == IPv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+// matches reports whether ip belongs to the family.
+func (f Family) matches(ip net.IP) bool {
+	return (ip.To4() != nil) == (f == IPv4)
+}
+
+// Provider discovers the machine's public address.
+type Provider interface {
+	// Name identifies the provider in logs and output, e.g. "ipify".
+	Name() string
+	// Get returns the public address of the given family. Errors name the provider.
+	Get(ctx context.Context, family Family) (net.IP, error)
+}
+
+// Result is a public address and the provider that found it.
+type Result struct {
+	IP net.IP
+	// Provider is the name of the provider that found IP.
+	Provider string
+	// Trace is set when IP came from Cloudflare's trace endpoint.
+	Trace *Trace
+}
+
+// Names of the built-in providers, as used in the config.
+const (
+	ProviderIpify      = "ipify"
+	ProviderIcanhazip  = "icanhazip"
+	ProviderIfconfig   = "ifconfig.co"
+	ProviderCloudflare = "cloudflare"
+)
+
+// DefaultProviders is the order providers are tried in when none is configured.
+var DefaultProviders = []string{ProviderIpify, ProviderIcanhazip, ProviderIfconfig}
+
+// Builtin returns the built-in provider with the given name.
+func Builtin(name string) (Provider, bool) {
+	switch name {
+	case ProviderIpify:
+		return ipify, true
+	case ProviderIcanhazip:
+		return &HTTPProvider{name: ProviderIcanhazip, urlV4: "https://ipv4.icanhazip.com", urlV6: "https://ipv6.icanhazip.com", extract: plainText}, true
+	case ProviderIfconfig:
+		// ifconfig.co answers with the address of whichever family the request used
+		return &HTTPProvider{name: ProviderIfconfig, urlV4: "https://ifconfig.co/ip", urlV6: "https://ifconfig.co/ip", extract: plainText}, true
+	case ProviderCloudflare:
+		return cloudflareTrace, true
+	}
+	return nil, false
+}
+
+var ipify = &HTTPProvider{name: ProviderIpify, urlV4: ipv4URL, urlV6: ipv6URL, extract: plainText}
+
+// Detect asks each provider in order for the public address of the family
+// and returns the first answer. The errors of every provider are returned
+// together if none of them found an address, or ErrNoIPv4 or ErrNoIPv6
+// alone if none of them could be reached over the family.
+func Detect(ctx context.Context, providers []Provider, family Family) (Result, error) {
+	var errs []error
+	for _, p := range providers {
+		result, err := lookup(ctx, p, family)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		cache(family, result.IP)
+		return result, nil
+	}
+	return Result{}, detectError(family, errs)
+}
+
+// lookup asks p for the address of the family, with the trace if p is Cloudflare's.
+func lookup(ctx context.Context, p Provider, family Family) (Result, error) {
+	if t, ok := p.(*TraceProvider); ok {
+		addr, trace, err := t.Trace(ctx, family)
+		return Result{IP: addr, Provider: p.Name(), Trace: trace}, err
+	}
+	addr, err := p.Get(ctx, family)
+	return Result{IP: addr, Provider: p.Name()}, err
+}
+
+// errUnsupported is wrapped by the error of a provider asked for a family it has no URL for.
+var errUnsupported = errors.New("family not supported")
+
+// detectError combines the errors of providers that all failed. It is
+// ErrNoIPv4 or ErrNoIPv6 alone when every provider that supports the family
+// failed to connect over it.
+func detectError(family Family, errs []error) error {
+	noConnectivity := false
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, family.errNoConnectivity()):
+			noConnectivity = true
+		case errors.Is(err, errUnsupported):
+		default:
+			return errors.Join(errs...)
+		}
+	}
+	if noConnectivity {
+		return family.errNoConnectivity()
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no %s provider configured", family)
+	}
+	return errors.Join(errs...)
+}
+
+// cache remembers addr as the last detected address of its family.
+func cache(family Family, addr net.IP) {
+	if family == IPv6 {
+		cachedIPv6 = addr
+	} else {
+		cachedIP = addr
+	}
+}
+
+// HTTPProvider fetches the public address from a web service.
+type HTTPProvider struct {
+	name string
+	// urlV4 and urlV6 are requested for each family; empty means the family is not supported.
+	urlV4, urlV6 string
+	// extract pulls the address out of the response body.
+	extract func(body []byte) (string, error)
+}
+
+// NewCustom creates a provider for a web service of your own. The address is
+// taken from the body with pattern (the first group if it has one, otherwise
+// the whole match), or from the JSON field at jsonPath (e.g. "wan.ip"), or
+// from the whole body if neither is set. An empty URL disables that family.
+func NewCustom(name, urlV4, urlV6, pattern, jsonPath string) (Provider, error) {
+	p := &HTTPProvider{name: name, urlV4: urlV4, urlV6: urlV6, extract: plainText}
+	switch {
+	case pattern != "" && jsonPath != "":
+		return nil, fmt.Errorf("ip provider %s: set either regex or json_path, not both", name)
+	case pattern != "":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("ip provider %s: invalid regex: %w", name, err)
+		}
+		p.extract = func(body []byte) (string, error) { return matchRegexp(re, body) }
+	case jsonPath != "":
+		p.extract = func(body []byte) (string, error) { return lookupJSON(body, jsonPath) }
+	}
+	return p, nil
+}
+
+// Name returns the provider's name.
+func (p *HTTPProvider) Name() string {
+	return p.name
+}
+
+// Get fetches the address of the family from the provider's URL for it.
+func (p *HTTPProvider) Get(ctx context.Context, family Family) (net.IP, error) {
+	body, err := p.fetch(ctx, family)
+	if err != nil {
+		return nil, err
+	}
+	return p.parse(family, body)
+}
+
+// fetch returns the body of the provider's URL for the family.
+func (p *HTTPProvider) fetch(ctx context.Context, family Family) ([]byte, error) {
+	url := p.urlV4
+	if family == IPv6 {
+		url = p.urlV6
+	}
+	if url == "" {
+		return nil, fmt.Errorf("%s does not support %s: %w", p.name, family, errUnsupported)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s URL: %w", p.name, err)
+	}
+	resp, err := clientFor(family).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch IP from %s: %w", p.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", p.name, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", p.name, err)
+	}
+	return body, nil
+}
+
+// parse extracts the address of the family from a response body.
+func (p *HTTPProvider) parse(family Family, body []byte) (net.IP, error) {
+	ipStr, err := p.extract(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s response: %w", p.name, err)
+	}
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP returned from %s: %s", p.name, ipStr)
+	}
+	if !family.matches(ip) {
+		return nil, fmt.Errorf("%s returned a non-%s address: %s", p.name, family, ip)
+	}
+	return ip, nil
+}
+
+// plainText reads the body as the address itself.
+func plainText(body []byte) (string, error) {
+	return strings.TrimSpace(string(body)), nil
+}
+
+// matchRegexp returns the first group of re in body, or the whole match if re has no groups.
+func matchRegexp(re *regexp.Regexp, body []byte) (string, error) {
+	m := re.FindSubmatch(body)
+	if m == nil {
+		return "", fmt.Errorf("no match for %s", re)
+	}
+	if len(m) > 1 {
+		return strings.TrimSpace(string(m[1])), nil
+	}
+	return strings.TrimSpace(string(m[0])), nil
+}
+
+// lookupJSON returns the string at path in the JSON body. The path is made
+// of dot-separated object keys and array indexes, e.g. "interfaces.0.ip".
+func lookupJSON(body []byte, path string) (string, error) {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", err
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("no index %s in %s", key, path)
+			}
+			v = node[i]
+		default:
+			return "", fmt.Errorf("no field %s in %s", key, path)
+		}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s is not a string", path)
+	}
+	return s, nil
+}
+
+// ErrNoConsensus means the providers answered but too few of them agreed on
+// one address to trust it.
+var ErrNoConsensus = errors.New("IP providers did not agree on the public address")
+
+// answer is one provider's reply in a consensus lookup.
+type answer struct {
+	Result
+	err error
+}
+
+func (a answer) String() string {
+	if a.err != nil {
+		return a.Provider + "=error"
+	}
+	return a.Provider + "=" + a.IP.String()
+}
+
+// Consensus asks every provider in parallel for the public address of the
+// family and returns the address at least quorum of them agree on. It
+// returns an error matching ErrNoConsensus, listing every provider's answer,
+// unless exactly one address reaches the quorum; and the providers' errors together if
+// none of them answered at all.
+func Consensus(ctx context.Context, providers []Provider, family Family, quorum int) (Result, error) {
+	answers := make([]answer, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := lookup(ctx, p, family)
+			answers[i] = answer{Result: result, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var (
+		errs  []error
+		votes = make(map[string][]string)
+		trace = make(map[string]*Trace)
+	)
+	for _, a := range answers {
+		if a.err != nil {
+			errs = append(errs, a.err)
+			continue
+		}
+		addr := a.IP.String()
+		votes[addr] = append(votes[addr], a.Provider)
+		if a.Trace != nil && trace[addr] == nil {
+			trace[addr] = a.Trace
+		}
+	}
+	if len(votes) == 0 {
+		return Result{}, detectError(family, errs)
+	}
+
+	// A quorum of half the providers or less can be reached by two addresses
+	// at once, which is no more an agreement than reaching none
+	var agreed []string
+	for addr, names := range votes {
+		if len(names) >= quorum {
+			agreed = append(agreed, addr)
+		}
+	}
+	if len(agreed) == 1 {
+		ip := net.ParseIP(agreed[0])
+		cache(family, ip)
+		return Result{IP: ip, Provider: strings.Join(votes[agreed[0]], ", "), Trace: trace[agreed[0]]}, nil
+	}
+
+	list := make([]string, len(answers))
+	for i, a := range answers {
+		list[i] = a.String()
+	}
+	return Result{}, fmt.Errorf("%w: %d of %d needed to agree on the %s address, got %s",
+		ErrNoConsensus, quorum, len(providers), family, strings.Join(list, " "))
+}
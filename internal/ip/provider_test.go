@@ -0,0 +1,120 @@
+package ip
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// routeTransport answers each request with the body registered for its host, or a 503.
+type routeTransport map[string]string
+
+func (rt routeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := rt[req.URL.Host]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func useTransport(t *testing.T, rt http.RoundTripper) {
+	t.Helper()
+	oldClient := client
+	client = &http.Client{Transport: rt}
+	t.Cleanup(func() { client = oldClient })
+}
+
+func TestDetectFallsBackInOrder(t *testing.T) {
+	useTransport(t, routeTransport{"ipv4.icanhazip.com": "198.51.100.4\n"})
+
+	ipifyProvider, _ := Builtin(ProviderIpify)
+	icanhazip, _ := Builtin(ProviderIcanhazip)
+
+	result, err := Detect(context.Background(), []Provider{ipifyProvider, icanhazip}, IPv4)
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	if result.IP.String() != "198.51.100.4" || result.Provider != ProviderIcanhazip {
+		t.Errorf("Expected 198.51.100.4 via icanhazip, got %s via %s", result.IP, result.Provider)
+	}
+
+	if _, err := Detect(context.Background(), []Provider{ipifyProvider}, IPv4); err == nil {
+		t.Error("Expected an error when every provider fails")
+	}
+}
+
+func TestConsensusNeedsQuorum(t *testing.T) {
+	ipifyProvider, _ := Builtin(ProviderIpify)
+	icanhazip, _ := Builtin(ProviderIcanhazip)
+	ifconfig, _ := Builtin(ProviderIfconfig)
+	providers := []Provider{ipifyProvider, icanhazip, ifconfig}
+
+	useTransport(t, routeTransport{
+		"api.ipify.org":      "198.51.100.4",
+		"ipv4.icanhazip.com": "198.51.100.4\n",
+		"ifconfig.co":        "203.0.113.9\n",
+	})
+	result, err := Consensus(context.Background(), providers, IPv4, 2)
+	if err != nil {
+		t.Fatalf("Consensus failed: %v", err)
+	}
+	if result.IP.String() != "198.51.100.4" || result.Provider != "ipify, icanhazip" {
+		t.Errorf("Expected 198.51.100.4 via ipify, icanhazip, got %s via %s", result.IP, result.Provider)
+	}
+
+	_, err = Consensus(context.Background(), providers, IPv4, 3)
+	if !errors.Is(err, ErrNoConsensus) {
+		t.Fatalf("Expected ErrNoConsensus, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "ifconfig.co=203.0.113.9") {
+		t.Errorf("Expected the error to list each provider's answer, got %v", err)
+	}
+
+	useTransport(t, routeTransport{})
+	if _, err := Consensus(context.Background(), providers, IPv4, 2); err == nil || errors.Is(err, ErrNoConsensus) {
+		t.Errorf("Expected a plain detection error when no provider answers, got %v", err)
+	}
+}
+
+func TestCustomProviders(t *testing.T) {
+	useTransport(t, routeTransport{
+		"router.lan": `{"wan":{"addresses":["198.51.100.8"]}}`,
+		"status.lan": "<td>WAN IP</td><td>198.51.100.9</td>",
+	})
+
+	jsonProvider, err := NewCustom("router", "http://router.lan/status", "", "", "wan.addresses.0")
+	if err != nil {
+		t.Fatalf("NewCustom failed: %v", err)
+	}
+	regexProvider, err := NewCustom("status", "http://status.lan/", "", `WAN IP</td><td>([0-9.]+)`, "")
+	if err != nil {
+		t.Fatalf("NewCustom failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		provider Provider
+		want     string
+	}{
+		{jsonProvider, "198.51.100.8"},
+		{regexProvider, "198.51.100.9"},
+	} {
+		got, err := tt.provider.Get(context.Background(), IPv4)
+		if err != nil {
+			t.Errorf("%s: Get failed: %v", tt.provider.Name(), err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.provider.Name(), tt.want, got)
+		}
+	}
+
+	if _, err := jsonProvider.Get(context.Background(), IPv6); err == nil {
+		t.Error("Expected an error for a family without a URL")
+	}
+	if _, err := NewCustom("bad", "http://x/", "", "(", ""); err == nil {
+		t.Error("Expected an invalid regex to be rejected")
+	}
+}
@@ -0,0 +1,67 @@
+package ip
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Trace is what Cloudflare's /cdn-cgi/trace reports besides the address.
+type Trace struct {
+	// Colo is the Cloudflare data center that answered, e.g. "AMS".
+	Colo string
+	// Warp is "on" or "plus" when the request went through Cloudflare WARP,
+	// in which case the address is WARP's rather than this machine's.
+	Warp string
+}
+
+// TraceProvider asks Cloudflare itself for the public address, using the
+// /cdn-cgi/trace endpoint of the 1.1.1.1 resolver.
+type TraceProvider struct {
+	HTTPProvider
+}
+
+var cloudflareTrace = &TraceProvider{HTTPProvider{
+	name:    ProviderCloudflare,
+	urlV4:   "https://1.1.1.1/cdn-cgi/trace",
+	urlV6:   "https://[2606:4700:4700::1111]/cdn-cgi/trace",
+	extract: traceAddress,
+}}
+
+// Trace fetches the address of the family along with the rest of the trace.
+func (p *TraceProvider) Trace(ctx context.Context, family Family) (net.IP, *Trace, error) {
+	body, err := p.fetch(ctx, family)
+	if err != nil {
+		return nil, nil, err
+	}
+	addr, err := p.parse(family, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	fields := parseTrace(body)
+	return addr, &Trace{Colo: fields["colo"], Warp: fields["warp"]}, nil
+}
+
+// traceAddress returns the ip= field of a trace.
+func traceAddress(body []byte) (string, error) {
+	addr, ok := parseTrace(body)["ip"]
+	if !ok {
+		return "", fmt.Errorf("no ip= line in trace")
+	}
+	return addr, nil
+}
+
+// parseTrace reads the key=value lines of a trace.
+func parseTrace(body []byte) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,31 @@
+package ip
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCloudflareTrace(t *testing.T) {
+	useTransport(t, routeTransport{
+		"1.1.1.1": "fl=29f1\nh=1.1.1.1\nip=198.51.100.4\nts=1760000000.123\nvisit_scheme=https\ncolo=AMS\nsliver=none\nhttp=http/2\nloc=NL\nwarp=off\ngateway=off\n",
+	})
+
+	p, ok := Builtin(ProviderCloudflare)
+	if !ok {
+		t.Fatal("Expected cloudflare to be a built-in provider")
+	}
+	result, err := Detect(context.Background(), []Provider{p}, IPv4)
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	if result.IP.String() != "198.51.100.4" {
+		t.Errorf("Expected 198.51.100.4, got %s", result.IP)
+	}
+	if result.Trace == nil || result.Trace.Colo != "AMS" || result.Trace.Warp != "off" {
+		t.Errorf("Expected colo AMS and warp off, got %+v", result.Trace)
+	}
+
+	if _, err := traceAddress([]byte("colo=AMS\n")); err == nil {
+		t.Error("Expected an error for a trace without an ip= line")
+	}
+}
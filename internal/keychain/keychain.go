@@ -11,6 +11,8 @@ import (
 const (
 	service = "cloudflare-ddns"
 	user    = "api-token"
+	// globalKeyUser holds the legacy Global API Key, used with auth = "global_key".
+	globalKeyUser = "global-api-key"
 )
 
 var ErrNotConfigured = errors.New("keychain entry not configured")
@@ -26,16 +28,35 @@ func Set(token string) error {
 // Get retrieves the API token from the system keychain.
 func Get() (string, error) {
 	// Allow overriding token via environment variable (useful for Docker)
-	if envToken := os.Getenv("CLOUDFLARE_API_TOKEN"); envToken != "" {
-		return envToken, nil
+	return get(user, "CLOUDFLARE_API_TOKEN")
+}
+
+// SetGlobalKey stores the Global API Key in the system keychain.
+func SetGlobalKey(key string) error {
+	if err := keyring.Set(service, globalKeyUser, key); err != nil {
+		return fmt.Errorf("failed to store Global API Key in keychain: %w", err)
+	}
+	return nil
+}
+
+// GetGlobalKey retrieves the Global API Key from the system keychain.
+func GetGlobalKey() (string, error) {
+	return get(globalKeyUser, "CLOUDFLARE_API_KEY")
+}
+
+// get returns the secret stored under name, or the value of the environment
+// variable env if it is set.
+func get(name, env string) (string, error) {
+	if value := os.Getenv(env); value != "" {
+		return value, nil
 	}
 
-	token, err := keyring.Get(service, user)
+	secret, err := keyring.Get(service, name)
 	if err != nil {
 		if errors.Is(err, keyring.ErrNotFound) {
 			return "", ErrNotConfigured
 		}
-		return "", fmt.Errorf("failed to retrieve token from keychain: %w", err)
+		return "", fmt.Errorf("failed to retrieve %s from keychain: %w", name, err)
 	}
-	return token, nil
+	return secret, nil
 }
@@ -0,0 +1,98 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
+	"github.com/jon-frankel/cloudflare-ddns/internal/ip"
+)
+
+// detection holds the public address lookup for one family, shared by all records.
+type detection struct {
+	ip net.IP
+	// provider names the IP provider that found ip.
+	provider string
+	// trace is what Cloudflare reported along with ip, if it came from the cloudflare provider.
+	trace *ip.Trace
+	err   error
+}
+
+// ipProviders builds the providers listed in the [ip] config section, in order.
+func ipProviders(cfg config.IPConfig) ([]ip.Provider, error) {
+	custom := make(map[string]config.CustomProvider)
+	for _, c := range cfg.Custom {
+		custom[c.Name] = c
+	}
+	interfaces := make(map[string]config.InterfaceProvider)
+	for _, i := range cfg.Interfaces {
+		interfaces[i.Name] = i
+	}
+
+	var providers []ip.Provider
+	for _, name := range cfg.ProviderNames() {
+		if p, ok := ip.Builtin(name); ok {
+			providers = append(providers, p)
+			continue
+		}
+		var (
+			p   ip.Provider
+			err error
+		)
+		if i, ok := interfaces[name]; ok {
+			p, err = ip.NewInterface(i.Name, i.Interface, i.Subnets, i.SkipTemporary)
+		} else {
+			c := custom[name]
+			p, err = ip.NewCustom(c.Name, c.URL, c.URLv6, c.Regex, c.JSONPath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// ipFamily returns the address family held by records of the given type.
+func ipFamily(recordType string) ip.Family {
+	if recordType == config.TypeAAAA {
+		return ip.IPv6
+	}
+	return ip.IPv4
+}
+
+// detectAddresses looks up the public address once for every record type
+// used by at least one record or target, trying the providers in order, or
+// asking them all at once in consensus mode.
+func (u *Updater) detectAddresses(ctx context.Context, types []string) map[string]detection {
+	addrs := make(map[string]detection)
+	for _, recordType := range types {
+		if _, ok := addrs[recordType]; ok {
+			continue
+		}
+
+		var (
+			result ip.Result
+			err    error
+		)
+		if u.quorum > 0 {
+			result, err = ip.Consensus(ctx, u.providers, ipFamily(recordType), u.quorum)
+		} else {
+			result, err = ip.Detect(ctx, u.providers, ipFamily(recordType))
+		}
+		switch {
+		case ip.NoConnectivity(err):
+			slog.Info("No connectivity for address family", "type", recordType, "error", err)
+		case errors.Is(err, ip.ErrNoConsensus):
+			slog.Warn("IP providers disagree, skipping update", "type", recordType, "error", err)
+		case err != nil:
+			slog.Warn("Failed to detect public address", "type", recordType, "error", err)
+		default:
+			slog.Info("Detected public address", "type", recordType, "ip", result.IP.String(), "provider", result.Provider)
+		}
+		addrs[recordType] = detection{ip: result.IP, provider: result.Provider, trace: result.Trace, err: err}
+	}
+	return addrs
+}
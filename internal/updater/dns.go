@@ -0,0 +1,357 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/cloudflare"
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
+	"github.com/jon-frankel/cloudflare-ddns/internal/ip"
+)
+
+// updateFamily reconciles the hostname's records of the given type with the
+// current public address of the matching family.
+func (u *Updater) updateFamily(ctx context.Context, rec config.Record, recordType string, addr detection, create bool) *FamilyResult {
+	cfClient := u.client
+	hostname := rec.Hostname
+	result := &FamilyResult{RecordType: recordType}
+
+	if errors.Is(addr.err, ip.ErrNoConsensus) {
+		// The address is unknown, not missing: leave the record alone
+		result.Error = fmt.Errorf("skipped update: %w", addr.err)
+		return result
+	}
+	if addr.err != nil && !ip.NoConnectivity(addr.err) {
+		// The providers failed, which says nothing about the family being
+		// gone: leave the record alone and try again next cycle
+		result.Error = fmt.Errorf("failed to get public IP: %w", addr.err)
+		slog.Error("Failed to get public IP", "error", addr.err, "hostname", hostname, "type", recordType)
+		return result
+	}
+	if addr.err != nil {
+		result.NoIPv6 = errors.Is(addr.err, ip.ErrNoIPv6)
+		u.handleMissing(ctx, rec, result, addr.err)
+		return result
+	}
+	currentIP := addr.ip
+	result.CurrentIP = currentIP
+	result.Provider = addr.provider
+	result.Trace = addr.trace
+
+	// Every record we create or write is stamped with the ownership marker.
+	// Existing records keep the rest of their comment, see planUpdate.
+	now := time.Now()
+	comment := cloudflare.OwnershipComment(u.machine, now)
+	opts := cloudflare.RecordOptions{
+		Proxied: rec.ProxyMode().Bool(),
+		TTL:     int(rec.TTL),
+		Comment: &comment,
+	}
+
+	// Get current DNS records, creating one if requested
+	records, err := cfClient.ListRecords(ctx, hostname, recordType)
+	if err != nil {
+		result.Error = fmt.Errorf("failed to get DNS record: %w", err)
+		slog.Error("Failed to get DNS record", "error", err, "hostname", hostname, "type", recordType)
+		return result
+	}
+	result.Records = records
+
+	if len(records) == 0 {
+		if !create {
+			result.Error = fmt.Errorf("failed to get DNS record: %s %w for %s", recordType, cloudflare.ErrRecordNotFound, hostname)
+			slog.Error("Failed to get DNS record", "error", result.Error, "hostname", hostname, "type", recordType)
+			return result
+		}
+
+		created, err := cfClient.CreateRecord(ctx, hostname, currentIP, opts)
+		if err != nil {
+			result.Error = fmt.Errorf("failed to create DNS record: %w", err)
+			slog.Error("Failed to create DNS record", "error", err, "hostname", hostname, "type", recordType)
+			return result
+		}
+		setRecordState(result, created)
+		u.written[created.ID] = created.IP
+		result.Created = true
+		result.Updated = true
+		slog.Info("Created DNS record", "hostname", hostname, "type", recordType, "ip", currentIP.String())
+		return result
+	}
+
+	if len(records) > 1 {
+		records, err = u.resolveDuplicates(ctx, rec, result, records)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+	}
+
+	setRecordState(result, records[0])
+	result.OldIP = records[0].IP
+
+	// Changes are only planned here; the cycle applies them together, one batch per zone
+	var pending []pendingUpdate
+	for i, record := range records {
+		if skip, err := u.checkDrift(rec, result, record, currentIP); err != nil {
+			result.Error = err
+			return result
+		} else if skip {
+			continue
+		}
+
+		recordComment := cloudflare.RecordComment(record.Comment, u.machine, now)
+		recordOpts := opts
+		recordOpts.Comment = &recordComment
+		update, err := planUpdate(rec, record, currentIP, recordOpts)
+		if err != nil {
+			result.Error = fmt.Errorf("failed to update DNS record: %w", err)
+			slog.Error("Failed to update DNS record", "error", err, "hostname", hostname, "type", recordType, "id", record.ID, "oldIP", record.IP.String(), "newIP", currentIP.String())
+			return result
+		}
+		if update != nil {
+			pending = append(pending, pendingUpdate{result: result, update: *update, primary: i == 0})
+		}
+	}
+	result.pending = pending
+
+	if len(pending) == 0 && !result.Updated && !result.Paused {
+		slog.Info("DNS record is already up to date", "hostname", hostname, "type", recordType, "ip", currentIP.String())
+	}
+	return result
+}
+
+// pendingUpdate is a record change planned by updateFamily and applied by applyUpdates.
+type pendingUpdate struct {
+	result *FamilyResult
+	update cloudflare.RecordUpdate
+	// primary is set for the record whose state result reports.
+	primary bool
+}
+
+// applyUpdates applies the record changes planned during the cycle. The
+// changes for each zone are sent as one batch, so a zone is never left half
+// updated; if the batch is rejected, the client falls back to one update per
+// record and each family reports whether its own records were updated.
+func (u *Updater) applyUpdates(ctx context.Context, records []UpdateResult) {
+	var pending []pendingUpdate
+	for _, r := range records {
+		for _, f := range r.Families() {
+			pending = append(pending, f.pending...)
+			f.pending = nil
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	updates := make([]cloudflare.RecordUpdate, len(pending))
+	for i, p := range pending {
+		updates[i] = p.update
+	}
+
+	for i, res := range u.client.UpdateRecords(ctx, updates) {
+		p := pending[i]
+		record, newIP := p.update.Record, p.update.IP
+		if res.Error != nil {
+			// Keep the first failure; later ones for the same family are logged
+			if p.result.Error == nil {
+				p.result.Error = fmt.Errorf("failed to update DNS record: %w", res.Error)
+			}
+			slog.Error("Failed to update DNS record", "error", res.Error, "hostname", record.Name, "type", record.Type, "id", record.ID, "oldIP", record.IP.String(), "newIP", newIP.String())
+			continue
+		}
+
+		p.result.Updated = true
+		u.written[res.Record.ID] = res.Record.IP
+		if p.primary {
+			setRecordState(p.result, res.Record)
+		}
+		if !newIP.Equal(record.IP) {
+			slog.Info("Successfully updated DNS record IP", "hostname", record.Name, "type", record.Type, "oldIP", record.IP.String(), "newIP", newIP.String())
+		} else {
+			slog.Info("Successfully updated DNS record settings", "hostname", record.Name, "type", record.Type, "ip", newIP.String(), "proxied", res.Record.Proxied, "ttl", res.Record.TTL)
+		}
+	}
+}
+
+// setRecordState copies the state of the primary record into result.
+func setRecordState(result *FamilyResult, record *cloudflare.DNSRecord) {
+	result.RecordIP = record.IP
+	result.RecordProxied = record.Proxied
+	result.RecordTTL = record.TTL
+	result.RecordComment = record.Comment
+}
+
+// checkOwnership returns an error if rec requires ownership and record lacks the marker.
+func checkOwnership(rec config.Record, record *cloudflare.DNSRecord) error {
+	if !rec.OwnershipRequired() || record.Owned() {
+		return nil
+	}
+	slog.Warn("Refusing to modify DNS record not managed by cloudflare-ddns", "hostname", record.Name, "type", record.Type, "id", record.ID)
+	return fmt.Errorf("%s record %s for %s is not managed by cloudflare-ddns and require_ownership is set", record.Type, record.ID, record.Name)
+}
+
+// resolveDuplicates applies the record's duplicates policy when several records
+// of one type share the hostname. It returns the records that should be kept
+// in sync, or an error if the policy refuses to continue.
+func (u *Updater) resolveDuplicates(ctx context.Context, rec config.Record, result *FamilyResult, records []*cloudflare.DNSRecord) ([]*cloudflare.DNSRecord, error) {
+	hostname := rec.Hostname
+
+	switch rec.DuplicatePolicy() {
+	case config.DuplicatesUpdateAll:
+		slog.Info("Updating all duplicate DNS records", "hostname", hostname, "type", result.RecordType, "count", len(records))
+		return records, nil
+
+	case config.DuplicatesKeepOne:
+		keep := records[0]
+		// Prefer a record that already points at the current address
+		for _, record := range records {
+			if record.IP.Equal(result.CurrentIP) {
+				keep = record
+				break
+			}
+		}
+
+		for _, record := range records {
+			if record == keep {
+				continue
+			}
+			if err := checkOwnership(rec, record); err != nil {
+				return nil, err
+			}
+			if err := u.client.DeleteRecord(ctx, record); err != nil {
+				slog.Error("Failed to delete duplicate DNS record", "error", err, "hostname", hostname, "type", result.RecordType, "id", record.ID)
+				return nil, fmt.Errorf("failed to delete duplicate DNS record %s: %w", record.ID, err)
+			}
+			result.DuplicatesDeleted++
+			slog.Info("Deleted duplicate DNS record", "hostname", hostname, "type", result.RecordType, "id", record.ID, "ip", record.IP.String())
+		}
+		return []*cloudflare.DNSRecord{keep}, nil
+
+	default:
+		slog.Error("Refusing to update duplicate DNS records", "hostname", hostname, "type", result.RecordType, "count", len(records))
+		return nil, fmt.Errorf("found %d %s records for %s; set duplicates = \"update_all\" or \"keep_one\" to resolve", len(records), result.RecordType, hostname)
+	}
+}
+
+// ErrDrift means a managed record was changed by someone else since the
+// daemon last wrote it, and on_drift = "alert" left it alone.
+var ErrDrift = errors.New("record was changed outside cloudflare-ddns")
+
+// checkDrift recognizes a third-party edit: the record holds neither the
+// address the daemon last wrote nor the current public address. It applies
+// the record's on_drift policy and reports whether the record must be left
+// alone this cycle. Records the daemon has not written or confirmed since it
+// started are never considered drifted.
+func (u *Updater) checkDrift(rec config.Record, result *FamilyResult, record *cloudflare.DNSRecord, currentIP net.IP) (bool, error) {
+	if record.IP.Equal(currentIP) {
+		// The record is right again, whoever fixed it
+		u.written[record.ID] = currentIP
+		delete(u.paused, record.ID)
+		return false, nil
+	}
+
+	last, ok := u.written[record.ID]
+	if !ok || record.IP.Equal(last) {
+		return false, nil
+	}
+	result.Drift = true
+
+	switch rec.DriftPolicy() {
+	case config.OnDriftOverwrite:
+		slog.Warn("DNS record was changed outside cloudflare-ddns, overwriting", "hostname", record.Name, "type", record.Type, "id", record.ID, "lastWritten", last.String(), "recordIP", record.IP.String())
+		return false, nil
+
+	case config.OnDriftPause:
+		result.Paused = true
+		if !u.paused[record.ID] {
+			u.paused[record.ID] = true
+			slog.Warn("DNS record was changed outside cloudflare-ddns, pausing management until restart", "hostname", record.Name, "type", record.Type, "id", record.ID, "lastWritten", last.String(), "recordIP", record.IP.String())
+		}
+		return true, nil
+
+	default:
+		slog.Error("DNS record was changed outside cloudflare-ddns, leaving it alone", "hostname", record.Name, "type", record.Type, "id", record.ID, "lastWritten", last.String(), "recordIP", record.IP.String())
+		return true, fmt.Errorf("%s record %s for %s points at %s, not %s as last written: %w", record.Type, record.ID, record.Name, record.IP, last, ErrDrift)
+	}
+}
+
+// planUpdate returns the update record needs if its IP, proxy status or TTL
+// differs from what is wanted, or nil if nothing changes. Writing a record
+// also refreshes its ownership comment.
+func planUpdate(rec config.Record, record *cloudflare.DNSRecord, currentIP net.IP, opts cloudflare.RecordOptions) (*cloudflare.RecordUpdate, error) {
+	// Check if IP, proxy status or TTL needs update. With proxied = "preserve"
+	// whatever proxy setting the record has is left alone.
+	ipNeedsUpdate := !currentIP.Equal(record.IP)
+	proxyMismatch := opts.Proxied != nil && (record.Proxied == nil || *record.Proxied != *opts.Proxied)
+	ttlMismatch := opts.TTL != 0 && record.TTL != opts.TTL && !willBeProxied(record, opts)
+
+	if !ipNeedsUpdate && !proxyMismatch && !ttlMismatch {
+		return nil, nil
+	}
+
+	if err := checkOwnership(rec, record); err != nil {
+		return nil, err
+	}
+	return &cloudflare.RecordUpdate{Record: record, IP: currentIP, Opts: opts}, nil
+}
+
+// willBeProxied reports whether the record is proxied once opts are applied.
+// Cloudflare always reports an automatic TTL for proxied records, so a TTL
+// mismatch on them is not drift.
+func willBeProxied(record *cloudflare.DNSRecord, opts cloudflare.RecordOptions) bool {
+	proxied := record.Proxied
+	if opts.Proxied != nil {
+		proxied = opts.Proxied
+	}
+	return proxied != nil && *proxied
+}
+
+// handleMissing applies the configured on_missing policy when the machine
+// has no connectivity in result's family.
+func (u *Updater) handleMissing(ctx context.Context, rec config.Record, result *FamilyResult, detectErr error) {
+	cfClient := u.client
+	hostname := rec.Hostname
+	result.Missing = true
+
+	switch rec.MissingPolicy() {
+	case config.OnMissingKeep:
+		slog.Warn("Public address not available, leaving record unchanged", "hostname", hostname, "type", result.RecordType, "error", detectErr)
+
+	case config.OnMissingDelete:
+		records, err := cfClient.ListRecords(ctx, hostname, result.RecordType)
+		if err != nil {
+			result.Error = fmt.Errorf("failed to get DNS record: %w", err)
+			slog.Error("Failed to get DNS record", "error", err, "hostname", hostname, "type", result.RecordType)
+			return
+		}
+		result.Records = records
+		if len(records) == 0 {
+			slog.Info("Public address not available and no record to delete", "hostname", hostname, "type", result.RecordType)
+			return
+		}
+
+		for _, record := range records {
+			if err := checkOwnership(rec, record); err != nil {
+				result.Error = err
+				return
+			}
+			if err := cfClient.DeleteRecord(ctx, record); err != nil {
+				result.Error = fmt.Errorf("failed to delete DNS record: %w", err)
+				slog.Error("Failed to delete DNS record", "error", err, "hostname", hostname, "type", result.RecordType, "id", record.ID)
+				return
+			}
+			result.Deleted = true
+			slog.Info("Public address not available, deleted DNS record", "hostname", hostname, "type", result.RecordType, "ip", record.IP.String())
+		}
+		result.OldIP = records[0].IP
+
+	default:
+		result.Error = fmt.Errorf("failed to get public IP: %w", detectErr)
+		slog.Error("Failed to get public IP", "error", detectErr, "hostname", hostname, "type", result.RecordType)
+	}
+}
@@ -0,0 +1,117 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	cf "github.com/cloudflare/cloudflare-go"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
+)
+
+const (
+	homeIP    = "203.0.113.7"
+	newHomeIP = "203.0.113.8"
+	editedIP  = "198.51.100.50"
+)
+
+// homeRecord is the A record of home.example.com, pointing at homeIP.
+var homeRecord = cf.DNSRecord{ID: "rec-1", Type: "A", Name: "home.example.com", Content: homeIP}
+
+func TestDriftPolicies(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantErr     error
+		wantPaused  bool
+		wantUpdated bool
+		wantContent string
+	}{
+		{config.OnDriftAlert, ErrDrift, false, false, editedIP},
+		{config.OnDriftPause, nil, true, false, editedIP},
+		{config.OnDriftOverwrite, nil, false, true, homeIP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			api := newFakeAPI(homeRecord)
+			u := newTestUpdater(t, api, config.Config{Hostname: "home.example.com", OnDrift: tt.policy}, &fixedProvider{ip: net.ParseIP(homeIP)})
+
+			// The first cycle confirms the record, so the daemon knows what it should hold
+			if err := u.RunOnce(context.Background()).Err(); err != nil {
+				t.Fatalf("First cycle failed: %v", err)
+			}
+
+			api.edit("rec-1", editedIP)
+			f := u.RunOnce(context.Background()).Records[0].IPv4
+
+			if !f.Drift {
+				t.Error("Expected the outside edit to be reported as drift")
+			}
+			if !errors.Is(f.Error, tt.wantErr) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, f.Error)
+			}
+			if f.Paused != tt.wantPaused || f.Updated != tt.wantUpdated {
+				t.Errorf("Expected paused=%v updated=%v, got paused=%v updated=%v", tt.wantPaused, tt.wantUpdated, f.Paused, f.Updated)
+			}
+			if got := api.get("rec-1").Content; got != tt.wantContent {
+				t.Errorf("Expected the record to hold %s, got %s", tt.wantContent, got)
+			}
+		})
+	}
+}
+
+func TestIPChangeIsNotDrift(t *testing.T) {
+	api := newFakeAPI(homeRecord)
+	provider := &fixedProvider{ip: net.ParseIP(homeIP)}
+	u := newTestUpdater(t, api, config.Config{Hostname: "home.example.com"}, provider)
+
+	if err := u.RunOnce(context.Background()).Err(); err != nil {
+		t.Fatalf("First cycle failed: %v", err)
+	}
+
+	provider.ip = net.ParseIP(newHomeIP)
+	result := u.RunOnce(context.Background())
+	f := result.Records[0].IPv4
+	if err := result.Err(); err != nil || f.Drift || !f.Updated {
+		t.Fatalf("Expected a plain update to %s, got updated=%v drift=%v err=%v", newHomeIP, f.Updated, f.Drift, err)
+	}
+	if got := api.get("rec-1").Content; got != newHomeIP {
+		t.Errorf("Expected the record to hold %s, got %s", newHomeIP, got)
+	}
+
+	// The daemon now expects the new address, so the next cycle is quiet too
+	f = u.RunOnce(context.Background()).Records[0].IPv4
+	if f.Drift || f.Updated || f.Error != nil {
+		t.Errorf("Expected no change after the update, got updated=%v drift=%v err=%v", f.Updated, f.Drift, f.Error)
+	}
+}
+
+func TestPausedRecordResumesWhenCorrected(t *testing.T) {
+	api := newFakeAPI(homeRecord)
+	provider := &fixedProvider{ip: net.ParseIP(homeIP)}
+	u := newTestUpdater(t, api, config.Config{Hostname: "home.example.com", OnDrift: config.OnDriftPause}, provider)
+
+	if err := u.RunOnce(context.Background()).Err(); err != nil {
+		t.Fatalf("First cycle failed: %v", err)
+	}
+	api.edit("rec-1", editedIP)
+	if f := u.RunOnce(context.Background()).Records[0].IPv4; !f.Paused {
+		t.Fatal("Expected the edited record to be paused")
+	}
+
+	// Someone points the record back at the current address
+	api.edit("rec-1", homeIP)
+	if f := u.RunOnce(context.Background()).Records[0].IPv4; f.Paused || f.Drift {
+		t.Errorf("Expected the corrected record to be managed again, got paused=%v drift=%v", f.Paused, f.Drift)
+	}
+
+	provider.ip = net.ParseIP(newHomeIP)
+	if f := u.RunOnce(context.Background()).Records[0].IPv4; !f.Updated || f.Paused {
+		t.Errorf("Expected the record to follow the next IP change, got updated=%v paused=%v", f.Updated, f.Paused)
+	}
+	if got := api.get("rec-1").Content; got != newHomeIP {
+		t.Errorf("Expected the record to hold %s, got %s", newHomeIP, got)
+	}
+}
@@ -0,0 +1,130 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/cloudflare"
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
+)
+
+// EphemeralResult is the outcome of removing an ephemeral hostname's records,
+// either on shutdown or because a crashed run's lease expired.
+type EphemeralResult struct {
+	Hostname string
+	// Deleted lists the addresses of the removed records.
+	Deleted []net.IP
+	// Stale is the expired heartbeat that caused the removal, or nil on shutdown.
+	Stale *cloudflare.Heartbeat
+	Error error
+}
+
+// Claim takes ownership of the ephemeral records for the life of the daemon:
+// from now on update cycles create them when they are missing. Records whose
+// lease expired, because the run that owned them crashed without renewing the
+// heartbeat, are removed first. One result is returned per expired lease.
+func (u *Updater) Claim(ctx context.Context) []EphemeralResult {
+	u.claimed = true
+
+	var results []EphemeralResult
+	for _, rec := range u.cfg.AllRecords() {
+		if !rec.IsEphemeral() || rec.Lease <= 0 {
+			continue
+		}
+
+		txt, err := u.client.GetTXTRecord(ctx, rec.HeartbeatRecord())
+		if errors.Is(err, cloudflare.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			results = append(results, EphemeralResult{Hostname: rec.Hostname, Error: fmt.Errorf("failed to check lease: %w", err)})
+			slog.Error("Failed to check lease", "error", err, "hostname", rec.Hostname)
+			continue
+		}
+
+		hb, ok := cloudflare.ParseHeartbeat(txt.Content)
+		if !ok || time.Since(hb.LastCheck) <= rec.Lease {
+			continue
+		}
+
+		slog.Warn("Lease expired, removing records left behind", "hostname", rec.Hostname, "host", hb.Host, "lastCheck", hb.LastCheck.Format(time.RFC3339))
+		res := u.removeEphemeral(ctx, rec, hb.Host, txt)
+		res.Stale = &hb
+		results = append(results, res)
+	}
+	return results
+}
+
+// Release deletes the ephemeral records this machine wrote, along with their
+// heartbeat records, and stops creating them. It is called on shutdown.
+func (u *Updater) Release(ctx context.Context) []EphemeralResult {
+	u.claimed = false
+
+	var results []EphemeralResult
+	for _, rec := range u.cfg.AllRecords() {
+		if !rec.IsEphemeral() {
+			continue
+		}
+
+		var txt *cloudflare.TXTRecord
+		if name := rec.HeartbeatRecord(); name != "" {
+			found, err := u.client.GetTXTRecord(ctx, name)
+			switch {
+			case err == nil:
+				// Leave the heartbeat alone if another machine has taken over the hostname
+				if hb, ok := cloudflare.ParseHeartbeat(found.Content); ok && hb.WrittenBy(u.machine) {
+					txt = found
+				}
+			case !errors.Is(err, cloudflare.ErrRecordNotFound):
+				slog.Warn("Failed to get heartbeat record", "error", err, "hostname", rec.Hostname, "name", name)
+			}
+			delete(u.heartbeats, name)
+		}
+
+		results = append(results, u.removeEphemeral(ctx, rec, u.machine, txt))
+	}
+	return results
+}
+
+// removeEphemeral deletes the hostname's records written by host, then the
+// heartbeat record txt if it is not nil.
+func (u *Updater) removeEphemeral(ctx context.Context, rec config.Record, host string, txt *cloudflare.TXTRecord) EphemeralResult {
+	result := EphemeralResult{Hostname: rec.Hostname}
+
+	var errs []error
+	for _, recordType := range rec.RecordTypes() {
+		records, err := u.client.ListRecords(ctx, rec.Hostname, recordType)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get %s record: %w", recordType, err))
+			continue
+		}
+		for _, record := range records {
+			if owner, ok := cloudflare.ParseOwnership(record.Comment); !ok || !owner.WrittenBy(host) {
+				slog.Info("Leaving ephemeral record written by another machine", "hostname", rec.Hostname, "type", recordType, "ip", record.IP.String())
+				continue
+			}
+			if err := u.client.DeleteRecord(ctx, record); err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete %s record: %w", recordType, err))
+				continue
+			}
+			result.Deleted = append(result.Deleted, record.IP)
+			slog.Info("Deleted ephemeral DNS record", "hostname", rec.Hostname, "type", recordType, "ip", record.IP.String())
+		}
+	}
+
+	if txt != nil {
+		if err := u.client.DeleteTXTRecord(ctx, txt); err != nil && !errors.Is(err, cloudflare.ErrRecordNotFound) {
+			errs = append(errs, fmt.Errorf("failed to delete heartbeat: %w", err))
+		}
+	}
+
+	result.Error = errors.Join(errs...)
+	if result.Error != nil {
+		slog.Error("Failed to remove ephemeral records", "error", result.Error, "hostname", rec.Hostname)
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+package updater
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	cf "github.com/cloudflare/cloudflare-go"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/cloudflare"
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
+)
+
+const heartbeatName = "_ddns.home.example.com"
+
+// ephemeralConfig is an ephemeral home.example.com whose lease expires after
+// five minutes without a heartbeat.
+func ephemeralConfig() config.Config {
+	return config.Config{
+		Hostname:  "home.example.com",
+		Ephemeral: cf.BoolPtr(true),
+		Heartbeat: time.Minute,
+		Lease:     5 * time.Minute,
+	}
+}
+
+// heartbeatRecord is a heartbeat written by host at lastCheck.
+func heartbeatRecord(id, host string, lastCheck time.Time) cf.DNSRecord {
+	return cf.DNSRecord{
+		ID:      id,
+		Type:    "TXT",
+		Name:    heartbeatName,
+		Content: cloudflare.Heartbeat{Host: host, Version: "test", LastCheck: lastCheck}.String(),
+	}
+}
+
+// ownedRecord is an A record of home.example.com written by host.
+func ownedRecord(id, host, addr string) cf.DNSRecord {
+	return cf.DNSRecord{
+		ID:      id,
+		Type:    "A",
+		Name:    "home.example.com",
+		Content: addr,
+		Comment: cloudflare.OwnershipComment(host, time.Now().Add(-time.Hour)),
+	}
+}
+
+func TestClaimRemovesExpiredLease(t *testing.T) {
+	api := newFakeAPI(
+		heartbeatRecord("txt-1", "old-host", time.Now().Add(-time.Hour)),
+		ownedRecord("rec-old", "old-host", homeIP),
+		ownedRecord("rec-other", "other-host", editedIP),
+	)
+	u := newTestUpdater(t, api, ephemeralConfig(), &fixedProvider{ip: net.ParseIP(newHomeIP)})
+
+	results := u.Claim(context.Background())
+	if len(results) != 1 {
+		t.Fatalf("Expected one expired lease, got %d", len(results))
+	}
+	res := results[0]
+	if res.Error != nil {
+		t.Fatalf("Claim failed: %v", res.Error)
+	}
+	if res.Stale == nil || res.Stale.Host != "old-host" {
+		t.Errorf("Expected the stale heartbeat of old-host, got %+v", res.Stale)
+	}
+	if len(res.Deleted) != 1 || !res.Deleted[0].Equal(net.ParseIP(homeIP)) {
+		t.Errorf("Expected only %s to be deleted, got %v", homeIP, res.Deleted)
+	}
+	if api.get("rec-old") != nil || api.get("txt-1") != nil {
+		t.Error("Expected the crashed run's record and heartbeat to be removed")
+	}
+	if api.get("rec-other") == nil {
+		t.Error("Expected the record written by another machine to be left alone")
+	}
+}
+
+func TestClaimKeepsLiveLease(t *testing.T) {
+	api := newFakeAPI(
+		heartbeatRecord("txt-1", "old-host", time.Now().Add(-time.Minute)),
+		ownedRecord("rec-old", "old-host", homeIP),
+	)
+	u := newTestUpdater(t, api, ephemeralConfig(), &fixedProvider{ip: net.ParseIP(newHomeIP)})
+
+	if results := u.Claim(context.Background()); len(results) != 0 {
+		t.Fatalf("Expected no expired lease, got %+v", results)
+	}
+	if api.get("rec-old") == nil || api.get("txt-1") == nil {
+		t.Error("Expected the records of a live lease to be left alone")
+	}
+}
+
+func TestReleaseRemovesOwnRecords(t *testing.T) {
+	api := newFakeAPI()
+	u := newTestUpdater(t, api, ephemeralConfig(), &fixedProvider{ip: net.ParseIP(homeIP)})
+
+	u.Claim(context.Background())
+	if err := u.RunOnce(context.Background()).Err(); err != nil {
+		t.Fatalf("Update cycle failed: %v", err)
+	}
+	if len(api.named(heartbeatName, "TXT")) != 1 || len(api.named("home.example.com", "A")) != 1 {
+		t.Fatal("Expected the cycle to create this machine's record and heartbeat")
+	}
+
+	results := u.Release(context.Background())
+	if len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("Expected one successful release, got %+v", results)
+	}
+	if len(api.named(heartbeatName, "TXT")) != 0 {
+		t.Error("Expected the heartbeat to be removed")
+	}
+	if remaining := api.named("home.example.com", "A"); len(remaining) != 0 {
+		t.Errorf("Expected this machine's record to be removed, got %+v", remaining)
+	}
+}
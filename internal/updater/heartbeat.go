@@ -0,0 +1,60 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/cloudflare"
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
+)
+
+// Version is the agent version written into heartbeat records. It is set by cmd.SetVersion.
+var Version = "dev"
+
+// HeartbeatResult is the outcome of one update cycle for a hostname's heartbeat TXT record.
+type HeartbeatResult struct {
+	// Name is the TXT record's name, e.g. "_ddns.home.example.com".
+	Name string
+	// Heartbeat is the content last written, or the zero value if none was written yet.
+	Heartbeat cloudflare.Heartbeat
+	// Refreshed is set when the record was written during this cycle.
+	Refreshed bool
+	Error     error
+}
+
+// heartbeatSlack is how much earlier than its interval a heartbeat is
+// refreshed, so a cycle started by a slightly early or late tick does not
+// push the refresh back by a whole cycle.
+const heartbeatSlack = 5 * time.Second
+
+// updateHeartbeat refreshes the hostname's heartbeat TXT record once its
+// interval has passed since the last refresh, measured between the start
+// times of the cycles. The first cycle always writes it.
+func (u *Updater) updateHeartbeat(ctx context.Context, rec config.Record, start time.Time) *HeartbeatResult {
+	name := rec.HeartbeatRecord()
+	if name == "" {
+		return nil
+	}
+	result := &HeartbeatResult{Name: name}
+
+	last, ok := u.heartbeats[name]
+	if ok && start.Sub(last.LastCheck) < rec.Heartbeat-heartbeatSlack {
+		result.Heartbeat = last
+		return result
+	}
+
+	hb := cloudflare.Heartbeat{Host: u.machine, Version: Version, LastCheck: start}
+	if _, err := u.client.SetTXTRecord(ctx, name, hb.String()); err != nil {
+		result.Error = fmt.Errorf("failed to refresh heartbeat: %w", err)
+		slog.Error("Failed to refresh heartbeat", "error", err, "hostname", rec.Hostname, "name", name)
+		return result
+	}
+
+	u.heartbeats[name] = hb
+	result.Heartbeat = hb
+	result.Refreshed = true
+	slog.Debug("Refreshed heartbeat", "hostname", rec.Hostname, "name", name)
+	return result
+}
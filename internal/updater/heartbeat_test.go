@@ -0,0 +1,38 @@
+package updater
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
+)
+
+func TestHeartbeatRefreshesDespiteTickJitter(t *testing.T) {
+	rec := config.Record{Hostname: "home.example.com", Heartbeat: time.Minute}
+	start := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		next        time.Duration
+		wantRefresh bool
+	}{
+		{"early tick", time.Minute - 200*time.Millisecond, true},
+		{"late tick", time.Minute + 200*time.Millisecond, true},
+		{"within interval", 30 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := newTestUpdater(t, newFakeAPI(), config.Config{Hostname: rec.Hostname}, &fixedProvider{})
+
+			if hb := u.updateHeartbeat(context.Background(), rec, start); hb.Error != nil || !hb.Refreshed {
+				t.Fatalf("Expected the first cycle to write the heartbeat, got %+v", hb)
+			}
+			hb := u.updateHeartbeat(context.Background(), rec, start.Add(tt.next))
+			if hb.Error != nil || hb.Refreshed != tt.wantRefresh {
+				t.Errorf("Expected refreshed=%v for a cycle %s later, got %+v", tt.wantRefresh, tt.next, hb)
+			}
+		})
+	}
+}
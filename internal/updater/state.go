@@ -0,0 +1,90 @@
+package updater
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// state is what the daemon remembers across restarts and one-shot runs.
+type state struct {
+	// Gateway holds the addresses this machine added as a /32 to each Gateway
+	// location, by gatewayKey, so they can be removed once the address changes.
+	Gateway map[string][]string `json:"gateway,omitempty"`
+}
+
+// loadState reads the state file at path. A missing file is an empty state.
+func loadState(path string) (state, error) {
+	st := state{Gateway: make(map[string][]string)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, fmt.Errorf("failed to read state: %w", err)
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return state{Gateway: make(map[string][]string)}, fmt.Errorf("failed to parse state: %w", err)
+	}
+	if st.Gateway == nil {
+		st.Gateway = make(map[string][]string)
+	}
+	return st, nil
+}
+
+// save writes the state file at path, replacing it atomically.
+func (st state) save(path string) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
+
+// gatewayOwned returns the addresses this machine added to the Gateway
+// location with the given key, loading the state file on first use.
+func (u *Updater) gatewayOwned(key string) []net.IP {
+	if u.state == nil {
+		st, err := loadState(u.statePath)
+		if err != nil {
+			slog.Warn("Failed to load state, old Gateway networks may be left behind", "error", err, "path", u.statePath)
+		}
+		u.state = &st
+	}
+
+	var owned []net.IP
+	for _, s := range u.state.Gateway[key] {
+		if addr := net.ParseIP(s); addr != nil {
+			owned = append(owned, addr)
+		}
+	}
+	return owned
+}
+
+// setGatewayOwned records the addresses this machine added to the Gateway
+// location with the given key and saves the state file.
+func (u *Updater) setGatewayOwned(key string, owned []net.IP) error {
+	u.gatewayOwned(key)
+
+	addrs := make([]string, 0, len(owned))
+	for _, addr := range owned {
+		addrs = append(addrs, addr.String())
+	}
+	u.state.Gateway[key] = addrs
+	return u.state.save(u.statePath)
+}
@@ -0,0 +1,380 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/cloudflare"
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
+	"github.com/jon-frankel/cloudflare-ddns/internal/ip"
+)
+
+// Kinds of targets kept in sync besides DNS records.
+const (
+	TargetIPList     = "IP list"
+	TargetAccessRule = "access rule"
+	TargetPoolOrigin = "load balancer origin"
+	TargetGateway    = "Gateway location"
+)
+
+// TargetResult is the outcome of one update cycle for a target other than a
+// DNS record, such as an IP list entry.
+type TargetResult struct {
+	// Kind is one of the Target constants, e.g. TargetIPList.
+	Kind string
+	// Name identifies the target within its kind, e.g. the list name.
+	Name       string
+	RecordType string
+	CurrentIP  net.IP
+	// Provider names the IP provider that found CurrentIP.
+	Provider string
+	// Trace is what Cloudflare reported along with CurrentIP, if it came from the cloudflare provider.
+	Trace *ip.Trace
+	// Value is what the target holds after the cycle, e.g. "203.0.113.7".
+	Value string
+	// OldValue is what the target held before it was updated.
+	OldValue string
+	Updated  bool
+	// Created is set (along with Updated) when the target did not exist yet,
+	// e.g. the IP list had no entry for this machine.
+	Created bool
+	// Missing is set when the public address for the target's family could not be detected.
+	Missing bool
+	// NoIPv6 is set along with Missing when the machine has no IPv6 connectivity.
+	NoIPv6 bool
+	Error  error
+}
+
+// String describes the target, e.g. "IP list home_ips".
+func (t TargetResult) String() string {
+	return t.Kind + " " + t.Name
+}
+
+// Err returns the target's error with the target named, or nil.
+func (t TargetResult) Err() error {
+	if t.Error == nil {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", t, t.Error)
+}
+
+// updateTargets brings every configured target other than DNS records in
+// line with the detected addresses. It stops early on a rate limit.
+func (u *Updater) updateTargets(ctx context.Context, addrs map[string]detection) []TargetResult {
+	var updates []func() TargetResult
+	for _, list := range u.cfg.AllIPLists() {
+		updates = append(updates, func() TargetResult { return u.updateIPList(ctx, list, addrs[list.RecordType()]) })
+	}
+	for _, rule := range u.cfg.AccessRules {
+		updates = append(updates, func() TargetResult { return u.updateAccessRule(ctx, rule, addrs[rule.RecordType()]) })
+	}
+	for _, origin := range u.cfg.AllPoolOrigins() {
+		updates = append(updates, func() TargetResult { return u.updatePoolOrigin(ctx, origin, addrs[origin.RecordType()]) })
+	}
+	for _, loc := range u.cfg.AllGatewayLocations() {
+		updates = append(updates, func() TargetResult { return u.updateGatewayLocation(ctx, loc, addrs[config.TypeA]) })
+	}
+
+	var results []TargetResult
+	for _, update := range updates {
+		tr := update()
+		results = append(results, tr)
+		if isRateLimited(tr.Error) {
+			break
+		}
+	}
+	return results
+}
+
+// detectedOrFail copies the detected address into result and reports true,
+// or records the detection error and reports false. attrs identify the
+// target in the log, e.g. "list", list.Name.
+func detectedOrFail(result *TargetResult, addr detection, attrs ...any) bool {
+	if addr.err != nil {
+		result.Missing = true
+		result.NoIPv6 = errors.Is(addr.err, ip.ErrNoIPv6)
+		result.Error = fmt.Errorf("failed to get public IP: %w", addr.err)
+		attrs = append([]any{"error", addr.err}, attrs...)
+		slog.Error("Failed to get public IP", append(attrs, "type", result.RecordType)...)
+		return false
+	}
+	result.CurrentIP = addr.ip
+	result.Provider = addr.provider
+	result.Trace = addr.trace
+	return true
+}
+
+// targetTypes returns the record types of the public addresses the targets need.
+func (u *Updater) targetTypes() []string {
+	var types []string
+	for _, list := range u.cfg.AllIPLists() {
+		types = append(types, list.RecordType())
+	}
+	for _, rule := range u.cfg.AccessRules {
+		types = append(types, rule.RecordType())
+	}
+	for _, origin := range u.cfg.AllPoolOrigins() {
+		types = append(types, origin.RecordType())
+	}
+	if len(u.cfg.GatewayLocations) > 0 {
+		types = append(types, config.TypeA)
+	}
+	return types
+}
+
+// updateIPList keeps this machine's entry in an IP list pointed at the public
+// address. Entries are recognized by the ownership marker naming this machine
+// in their comment, so entries added by hand or by other machines are left alone.
+func (u *Updater) updateIPList(ctx context.Context, list config.IPList, addr detection) TargetResult {
+	result := TargetResult{Kind: TargetIPList, Name: list.Name, RecordType: list.RecordType()}
+
+	if !detectedOrFail(&result, addr, "list", list.Name) {
+		return result
+	}
+	value := cloudflare.IPListValue(addr.ip)
+
+	listID, items, err := u.client.IPListItems(ctx, list.AccountID, list.Name)
+	if err != nil {
+		result.Error = err
+		slog.Error("Failed to get IP list", "error", err, "list", list.Name)
+		return result
+	}
+
+	// Split this machine's entries of the address family into the stale ones
+	// and one that is current. The same list may also be configured for the
+	// other family, whose entry is that pass's business.
+	var (
+		stale   []*cloudflare.IPListItem
+		current bool
+	)
+	for _, item := range items {
+		if item.Value == value {
+			current = true
+			continue
+		}
+		if !item.SameFamily(addr.ip) {
+			continue
+		}
+		if owner, ok := cloudflare.ParseOwnership(item.Comment); ok && owner.WrittenBy(u.machine) {
+			stale = append(stale, item)
+		}
+	}
+	if len(stale) > 0 {
+		result.OldValue = stale[0].Value
+	}
+
+	if current && len(stale) == 0 {
+		result.Value = value
+		slog.Info("IP list is already up to date", "list", list.Name, "value", value)
+		return result
+	}
+
+	// The address may already be listed, e.g. by hand; then only stale entries go
+	add := value
+	if current {
+		add = ""
+	}
+	comment := cloudflare.OwnershipComment(u.machine, time.Now())
+	if err := u.client.ReplaceIPListItems(ctx, list.AccountID, listID, stale, add, comment); err != nil {
+		result.Error = err
+		slog.Error("Failed to update IP list", "error", err, "list", list.Name, "value", value)
+		return result
+	}
+
+	result.Value = value
+	result.Updated = true
+	result.Created = len(stale) == 0
+	slog.Info("Updated IP list", "list", list.Name, "oldValue", result.OldValue, "newValue", value)
+	return result
+}
+
+// updateAccessRule keeps the zone's IP access rule identified by its notes
+// pointed at the public address with the configured mode, creating it if missing.
+func (u *Updater) updateAccessRule(ctx context.Context, rule config.AccessRule, addr detection) TargetResult {
+	result := TargetResult{Kind: TargetAccessRule, Name: rule.Zone, RecordType: rule.RecordType()}
+
+	if !detectedOrFail(&result, addr, "zone", rule.Zone) {
+		return result
+	}
+	mode := rule.RuleMode()
+
+	rules, err := u.client.FindAccessRules(ctx, rule.Zone, rule.Notes)
+	if errors.Is(err, cloudflare.ErrTargetNotFound) {
+		created, err := u.client.CreateAccessRule(ctx, rule.Zone, mode, addr.ip, rule.Notes)
+		if err != nil {
+			result.Error = err
+			slog.Error("Failed to create access rule", "error", err, "zone", rule.Zone, "notes", rule.Notes)
+			return result
+		}
+		result.Value = created.Value
+		result.Created = true
+		result.Updated = true
+		slog.Info("Created access rule", "zone", rule.Zone, "notes", rule.Notes, "mode", mode, "ip", created.Value)
+		return result
+	}
+	if err != nil {
+		result.Error = err
+		slog.Error("Failed to get access rule", "error", err, "zone", rule.Zone, "notes", rule.Notes)
+		return result
+	}
+	existing := currentAccessRule(rules, addr.ip)
+	result.Value = existing.Value
+
+	// A replacement whose old rule could not be deleted leaves rules with the
+	// same notes behind; remove them so only the kept rule matches
+	var errs []error
+	for _, extra := range rules {
+		if extra == existing {
+			continue
+		}
+		if err := u.client.DeleteAccessRule(ctx, extra); err != nil {
+			errs = append(errs, err)
+			slog.Error("Failed to delete duplicate access rule", "error", err, "zone", rule.Zone, "notes", rule.Notes, "ip", extra.Value)
+			continue
+		}
+		slog.Info("Deleted duplicate access rule", "zone", rule.Zone, "notes", rule.Notes, "ip", extra.Value)
+	}
+
+	ipNeedsUpdate := !addr.ip.Equal(net.ParseIP(existing.Value))
+	switch {
+	case ipNeedsUpdate:
+		replaced, err := u.client.ReplaceAccessRule(ctx, rule.Zone, existing, mode, addr.ip)
+		if replaced != nil {
+			result.OldValue = existing.Value
+			result.Value = replaced.Value
+			result.Updated = true
+		}
+		if err != nil {
+			errs = append(errs, err)
+			slog.Error("Failed to update access rule", "error", err, "zone", rule.Zone, "notes", rule.Notes)
+		} else {
+			slog.Info("Updated access rule", "zone", rule.Zone, "notes", rule.Notes, "oldIP", existing.Value, "newIP", replaced.Value)
+		}
+
+	case existing.Mode != mode:
+		if _, err := u.client.SetAccessRuleMode(ctx, existing, mode); err != nil {
+			errs = append(errs, err)
+			slog.Error("Failed to update access rule", "error", err, "zone", rule.Zone, "notes", rule.Notes)
+		} else {
+			result.Updated = true
+			slog.Info("Updated access rule mode", "zone", rule.Zone, "notes", rule.Notes, "oldMode", existing.Mode, "newMode", mode)
+		}
+
+	default:
+		slog.Info("Access rule is already up to date", "zone", rule.Zone, "notes", rule.Notes, "ip", existing.Value)
+	}
+	result.Error = errors.Join(errs...)
+	return result
+}
+
+// currentAccessRule returns the rule matching ip if there is one, since a
+// leftover of an earlier replacement holds an older address, and otherwise
+// the first rule.
+func currentAccessRule(rules []*cloudflare.AccessRule, ip net.IP) *cloudflare.AccessRule {
+	for _, r := range rules {
+		if ip.Equal(net.ParseIP(r.Value)) {
+			return r
+		}
+	}
+	return rules[0]
+}
+
+// updatePoolOrigin keeps a Load Balancer pool origin's address pointed at the
+// public address. Nothing else in the pool is changed.
+func (u *Updater) updatePoolOrigin(ctx context.Context, cfgOrigin config.PoolOrigin, addr detection) TargetResult {
+	name := cfgOrigin.Pool + "/" + cfgOrigin.Origin
+	result := TargetResult{Kind: TargetPoolOrigin, Name: name, RecordType: cfgOrigin.RecordType()}
+
+	if !detectedOrFail(&result, addr, "origin", name) {
+		return result
+	}
+
+	origin, err := u.client.GetPoolOrigin(ctx, cfgOrigin.AccountID, cfgOrigin.Pool, cfgOrigin.Origin)
+	if err != nil {
+		result.Error = err
+		slog.Error("Failed to get load balancer origin", "error", err, "origin", name)
+		return result
+	}
+	result.Value = origin.Address
+
+	if addr.ip.Equal(net.ParseIP(origin.Address)) {
+		slog.Info("Load balancer origin is already up to date", "origin", name, "address", origin.Address)
+		return result
+	}
+
+	if err := u.client.SetPoolOriginAddress(ctx, cfgOrigin.AccountID, origin, addr.ip.String()); err != nil {
+		result.Error = err
+		slog.Error("Failed to update load balancer origin", "error", err, "origin", name)
+		return result
+	}
+	result.OldValue = origin.Address
+	result.Value = addr.ip.String()
+	result.Updated = true
+	slog.Info("Updated load balancer origin", "origin", name, "oldAddress", origin.Address, "newAddress", result.Value)
+	return result
+}
+
+// updateGatewayLocation keeps a Gateway DNS location's source network at the
+// public IPv4 address as a /32. Only the /32s this machine added before, as
+// kept in the state file, are replaced; the location's other source networks
+// are kept.
+func (u *Updater) updateGatewayLocation(ctx context.Context, cfgLoc config.GatewayLocation, addr detection) TargetResult {
+	result := TargetResult{Kind: TargetGateway, Name: cfgLoc.Name, RecordType: config.TypeA}
+
+	if !detectedOrFail(&result, addr, "location", cfgLoc.Name) {
+		return result
+	}
+
+	loc, err := u.client.GetGatewayLocation(ctx, cfgLoc.AccountID, cfgLoc.Name)
+	if err != nil {
+		result.Error = err
+		slog.Error("Failed to get Gateway location", "error", err, "location", cfgLoc.Name)
+		return result
+	}
+	result.Value = strings.Join(loc.Networks, ", ")
+
+	key := gatewayKey(cfgLoc.AccountID, loc.ID)
+	owned := u.gatewayOwned(key)
+	networks, changed := loc.GatewayNetworks(addr.ip, owned)
+	if !changed {
+		if len(owned) != 1 || !owned[0].Equal(addr.ip) {
+			u.rememberGateway(key, cfgLoc.Name, addr.ip)
+		}
+		slog.Info("Gateway location is already up to date", "location", cfgLoc.Name, "networks", result.Value)
+		return result
+	}
+
+	// Remember the new address before writing it, so it is removed later
+	// even if the daemon stops before the update is confirmed
+	u.rememberGateway(key, cfgLoc.Name, append(owned, addr.ip)...)
+	if err := u.client.SetGatewayNetworks(ctx, cfgLoc.AccountID, loc, networks); err != nil {
+		result.Error = err
+		slog.Error("Failed to update Gateway location", "error", err, "location", cfgLoc.Name)
+		return result
+	}
+	u.rememberGateway(key, cfgLoc.Name, addr.ip)
+	result.OldValue = result.Value
+	result.Value = strings.Join(networks, ", ")
+	result.Updated = true
+	slog.Info("Updated Gateway location networks", "location", cfgLoc.Name, "oldNetworks", result.OldValue, "newNetworks", result.Value)
+	return result
+}
+
+// rememberGateway records owned as the addresses this machine added to the
+// Gateway location. A state file that cannot be written is only logged: the
+// location is up to date, but an old /32 may be left behind after a restart.
+func (u *Updater) rememberGateway(key, name string, owned ...net.IP) {
+	if err := u.setGatewayOwned(key, owned); err != nil {
+		slog.Warn("Failed to save Gateway networks to state file", "error", err, "location", name, "path", u.statePath)
+	}
+}
+
+// gatewayKey identifies a Gateway location in the state file.
+func gatewayKey(accountID, locationID string) string {
+	return "gateway/" + accountID + "/" + locationID
+}
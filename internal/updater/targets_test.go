@@ -0,0 +1,89 @@
+package updater
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+
+	cf "github.com/cloudflare/cloudflare-go"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
+)
+
+func TestIPListForBothFamilies(t *testing.T) {
+	api := newFakeAPI()
+	cfg := config.Config{AccountID: "acct-1", IPLists: []config.IPList{
+		{Name: "home_ips"},
+		{Name: "home_ips", Type: config.TypeAAAA},
+	}}
+	provider := &fixedProvider{ip: net.ParseIP(homeIP), ipv6: net.ParseIP("2001:db8:1:2::7")}
+	u := newTestUpdater(t, api, cfg, provider)
+
+	want := []string{"2001:db8:1:2::/64", homeIP}
+	for cycle := 1; cycle <= 2; cycle++ {
+		if err := u.RunOnce(context.Background()).Err(); err != nil {
+			t.Fatalf("Cycle %d failed: %v", cycle, err)
+		}
+		if got := api.listValues(); !slices.Equal(got, want) {
+			t.Fatalf("Expected the list to hold %v after cycle %d, got %v", want, cycle, got)
+		}
+	}
+
+	// An IPv4 change replaces only the IPv4 entry
+	provider.ip = net.ParseIP(newHomeIP)
+	if err := u.RunOnce(context.Background()).Err(); err != nil {
+		t.Fatalf("Cycle after the IP change failed: %v", err)
+	}
+	want = []string{"2001:db8:1:2::/64", newHomeIP}
+	if got := api.listValues(); !slices.Equal(got, want) {
+		t.Errorf("Expected the list to hold %v, got %v", want, got)
+	}
+}
+
+func TestAccessRuleKeepsCurrentAmongDuplicates(t *testing.T) {
+	// A replacement created the rule for the new address, but could not
+	// delete the old one, which is listed first
+	rule := func(id, value string) cf.AccessRule {
+		return cf.AccessRule{ID: id, Notes: "home", Mode: config.AccessRuleWhitelist,
+			Configuration: cf.AccessRuleConfiguration{Target: "ip", Value: value}}
+	}
+	api := newFakeAPI()
+	api.accessRules = []cf.AccessRule{rule("rule-old", homeIP), rule("rule-new", newHomeIP)}
+	cfg := config.Config{AccessRules: []config.AccessRule{{Zone: "example.com", Notes: "home"}}}
+	u := newTestUpdater(t, api, cfg, &fixedProvider{ip: net.ParseIP(newHomeIP)})
+
+	result := u.RunOnce(context.Background())
+	if err := result.Err(); err != nil {
+		t.Fatalf("Cycle failed: %v", err)
+	}
+	if tr := result.Targets[0]; tr.Updated || tr.Value != newHomeIP {
+		t.Errorf("Expected the current rule to be kept as it is, got updated=%v value=%s", tr.Updated, tr.Value)
+	}
+	if got := api.accessRuleValues("home"); !slices.Equal(got, []string{newHomeIP}) {
+		t.Errorf("Expected only the rule for %s to remain, got %v", newHomeIP, got)
+	}
+}
+
+func TestGatewayRemovesStaleNetworkAfterRestart(t *testing.T) {
+	api := newFakeAPI()
+	api.gateway = []string{"192.0.2.7/32"}
+	cfg := config.Config{AccountID: "acct-1", GatewayLocations: []config.GatewayLocation{{Name: "Branch office"}}}
+
+	u := newTestUpdater(t, api, cfg, &fixedProvider{ip: net.ParseIP(homeIP)})
+	if err := u.RunOnce(context.Background()).Err(); err != nil {
+		t.Fatalf("First cycle failed: %v", err)
+	}
+
+	// The daemon restarts with the same state file while the address changes
+	restarted := newTestUpdater(t, api, cfg, &fixedProvider{ip: net.ParseIP(newHomeIP)})
+	restarted.statePath = u.statePath
+	if err := restarted.RunOnce(context.Background()).Err(); err != nil {
+		t.Fatalf("Cycle after restart failed: %v", err)
+	}
+
+	want := []string{newHomeIP + "/32", "192.0.2.7/32"}
+	if got := api.gatewayNetworks(); !slices.Equal(got, want) {
+		t.Errorf("Expected networks %v, got %v", want, got)
+	}
+}
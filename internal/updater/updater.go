@@ -2,163 +2,399 @@ package updater
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"time"
 
 	"github.com/jon-frankel/cloudflare-ddns/internal/cloudflare"
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
 	"github.com/jon-frankel/cloudflare-ddns/internal/ip"
 	"github.com/jon-frankel/cloudflare-ddns/internal/keychain"
 )
 
-type UpdateResult struct {
+// FamilyResult is the outcome of one update cycle for a single address
+// family, i.e. the hostname's A or AAAA record.
+type FamilyResult struct {
+	RecordType    string
 	CurrentIP     net.IP
 	RecordIP      net.IP
 	OldIP         net.IP
 	RecordProxied *bool
+	RecordTTL     int
+	RecordComment string
 	Updated       bool
-	Error         error
+	// Created is set (along with Updated) when a missing record was created.
+	Created bool
+	// Missing is set when the machine has no connectivity in this family, so
+	// on_missing applied. Other detection failures only set Error.
+	Missing bool
+	// NoIPv6 is set along with Missing when the machine has no IPv6 connectivity.
+	NoIPv6 bool
+	// Deleted is set when the record was removed because the family went missing.
+	Deleted bool
+	// Records lists every matching record as found before this cycle changed anything.
+	Records []*cloudflare.DNSRecord
+	// Provider names the IP provider that found CurrentIP.
+	Provider string
+	// Trace is what Cloudflare reported along with CurrentIP, if it came from the cloudflare provider.
+	Trace *ip.Trace
+	// DuplicatesDeleted counts the extra records removed by duplicates = "keep_one".
+	DuplicatesDeleted int
+	// Drift is set when someone else changed the record since the daemon last wrote it.
+	Drift bool
+	// Paused is set when the record is left alone because of on_drift = "pause".
+	Paused bool
+	Error  error
+
+	// pending holds the record changes planned for this family until the cycle applies them.
+	pending []pendingUpdate
 }
 
-// RunOnce performs a single update cycle: fetch public IP, compare with Cloudflare record, update if needed.
-func RunOnce(ctx context.Context, hostname string) UpdateResult {
-	result := UpdateResult{}
+// UpdateResult is the outcome of one update cycle for a single hostname.
+// IPv4 and IPv6 are nil when the corresponding record type is not managed.
+type UpdateResult struct {
+	Hostname string
+	IPv4     *FamilyResult
+	IPv6     *FamilyResult
+	// Heartbeat is nil when the hostname has no heartbeat configured.
+	Heartbeat *HeartbeatResult
+}
 
-	// Get current public IP
-	currentIP, err := ip.Get()
-	if err != nil {
-		result.Error = fmt.Errorf("failed to get public IP: %w", err)
-		slog.Error("Failed to get public IP", "error", err)
-		return result
+// Families returns the results for the managed address families, A first.
+func (r UpdateResult) Families() []*FamilyResult {
+	var families []*FamilyResult
+	if r.IPv4 != nil {
+		families = append(families, r.IPv4)
+	}
+	if r.IPv6 != nil {
+		families = append(families, r.IPv6)
 	}
-	result.CurrentIP = currentIP
+	return families
+}
 
-	// Get API token from keychain
-	token, err := keychain.Get()
-	if err != nil {
-		result.Error = fmt.Errorf("failed to get API token from keychain: %w", err)
-		slog.Error("Failed to get API token", "error", err)
-		return result
+// Updated returns true if any record was created, updated or deleted.
+func (r UpdateResult) Updated() bool {
+	for _, f := range r.Families() {
+		if f.Updated || f.Deleted || f.DuplicatesDeleted > 0 {
+			return true
+		}
 	}
+	return false
+}
 
-	// Create Cloudflare client
-	cfClient, err := cloudflare.New(token)
-	if err != nil {
-		result.Error = fmt.Errorf("failed to create Cloudflare client: %w", err)
-		slog.Error("Failed to create Cloudflare client", "error", err)
-		return result
+// Err returns every per-family and heartbeat error joined together, or nil.
+func (r UpdateResult) Err() error {
+	var errs []error
+	for _, f := range r.Families() {
+		if f.Error != nil {
+			errs = append(errs, fmt.Errorf("%s %s record: %w", r.Hostname, f.RecordType, f.Error))
+		}
 	}
+	if r.Heartbeat != nil && r.Heartbeat.Error != nil {
+		errs = append(errs, fmt.Errorf("%s heartbeat: %w", r.Hostname, r.Heartbeat.Error))
+	}
+	return errors.Join(errs...)
+}
 
-	// Get current DNS record
-	record, err := cfClient.GetRecord(ctx, hostname)
-	if err != nil {
-		result.Error = fmt.Errorf("failed to get DNS record: %w", err)
-		slog.Error("Failed to get DNS record", "error", err, "hostname", hostname)
-		return result
+// CycleResult is the outcome of one update cycle across all configured records and targets.
+type CycleResult struct {
+	Records []UpdateResult
+	// Targets holds the results for targets other than DNS records, in config order.
+	Targets []TargetResult
+	// Error is set when the cycle could not start at all (e.g. missing token).
+	Error error
+	// RetryAt is set when Cloudflare rate-limited the cycle. No API requests
+	// are sent before it, and records not yet reached were skipped.
+	RetryAt time.Time
+}
+
+// Updated returns true if any record or target was created, updated or deleted.
+func (c CycleResult) Updated() bool {
+	for _, r := range c.Records {
+		if r.Updated() {
+			return true
+		}
 	}
-	result.RecordIP = record.IP
-	result.OldIP = record.IP
-	result.RecordProxied = record.Proxied
+	for _, t := range c.Targets {
+		if t.Updated {
+			return true
+		}
+	}
+	return false
+}
 
-	// Check if IP or proxy status needs update
-	ipNeedsUpdate := !currentIP.Equal(record.IP)
-	proxyDisabled := record.Proxied == nil || !*record.Proxied
-	needsUpdate := ipNeedsUpdate || proxyDisabled
+// Err returns the cycle error joined with every per-record and per-target error, or nil.
+func (c CycleResult) Err() error {
+	errs := []error{c.Error}
+	for _, r := range c.Records {
+		errs = append(errs, r.Err())
+	}
+	for _, t := range c.Targets {
+		errs = append(errs, t.Err())
+	}
+	return errors.Join(errs...)
+}
 
-	if !needsUpdate {
-		slog.Info("DNS record is already up to date", "hostname", hostname, "ip", currentIP.String())
-		return result
+// Permanent returns true if the cycle failed and retrying cannot fix it:
+// every record and target failed with a permanent error (see cloudflare.IsPermanent).
+func (c CycleResult) Permanent() bool {
+	if c.Error != nil {
+		return cloudflare.IsPermanent(c.Error)
+	}
+	if len(c.Records) == 0 && len(c.Targets) == 0 {
+		return false
+	}
+	for _, r := range c.Records {
+		for _, f := range r.Families() {
+			if !cloudflare.IsPermanent(f.Error) {
+				return false
+			}
+		}
+	}
+	for _, t := range c.Targets {
+		if !cloudflare.IsPermanent(t.Error) {
+			return false
+		}
 	}
+	return true
+}
+
+// Updater reconciles the configured records with the public IP. It keeps a
+// single Cloudflare client, and with it the zone ID cache, for its lifetime.
+type Updater struct {
+	cfg    config.Config
+	client *cloudflare.Client
+	// machine names this machine in the ownership comment of written records.
+	machine string
+	// heartbeats holds the last heartbeat written to each heartbeat record.
+	heartbeats map[string]cloudflare.Heartbeat
+	// written holds the address last written to or confirmed in each record, by record ID.
+	written map[string]net.IP
+	// paused holds the IDs of records left alone after drift with on_drift = "pause".
+	paused map[string]bool
+	// providers detect the public address, tried in order.
+	providers []ip.Provider
+	// quorum is how many providers must agree in consensus mode; 0 in fallback mode.
+	quorum int
+	// claimed is set between Claim and Release, while missing ephemeral records are created.
+	claimed bool
+	// statePath is the state file; state is loaded from it on first use.
+	statePath string
+	state     *state
+}
 
-	// Update the record
-	updatedRecord, err := cfClient.UpdateRecord(ctx, hostname, currentIP)
+// New creates an Updater for cfg using the API token, or the Global API Key
+// with auth = "global_key", from the keychain. The client is configured from
+// cfg.API; opts are applied after it, so callers such as tests can point the
+// client at a fake API.
+func New(cfg config.Config, opts ...cloudflare.Option) (*Updater, error) {
+	cfClient, err := newClient(cfg, append(clientOptions(cfg.API), opts...))
 	if err != nil {
-		result.Error = fmt.Errorf("failed to update DNS record: %w", err)
-		slog.Error("Failed to update DNS record", "error", err, "hostname", hostname, "oldIP", record.IP.String(), "newIP", currentIP.String())
-		return result
+		return nil, err
 	}
 
-	// Update result with the latest record state
-	result.RecordIP = updatedRecord.IP
-	result.RecordProxied = updatedRecord.Proxied
+	for _, rec := range cfg.AllRecords() {
+		if rec.ZoneID != "" {
+			cfClient.SetZoneID(rec.Hostname, rec.ZoneID)
+			// The heartbeat record lives next to the hostname, in the same zone
+			if name := rec.HeartbeatRecord(); name != "" {
+				cfClient.SetZoneID(name, rec.ZoneID)
+			}
+		}
+	}
+	for _, rule := range cfg.AccessRules {
+		if rule.ZoneID != "" {
+			cfClient.SetZoneID(rule.Zone, rule.ZoneID)
+		}
+	}
 
-	result.Updated = true
-	if ipNeedsUpdate {
-		slog.Info("Successfully updated DNS record IP", "hostname", hostname, "oldIP", record.IP.String(), "newIP", currentIP.String())
+	providers, err := ipProviders(cfg.IP)
+	if err != nil {
+		return nil, err
+	}
+	var quorum int
+	if cfg.IP.DetectMode() == config.IPModeConsensus {
+		quorum = cfg.IP.QuorumSize()
+	}
+
+	return &Updater{
+		cfg:        cfg,
+		client:     cfClient,
+		machine:    cfg.Machine(),
+		heartbeats: make(map[string]cloudflare.Heartbeat),
+		written:    make(map[string]net.IP),
+		paused:     make(map[string]bool),
+		providers:  providers,
+		quorum:     quorum,
+		statePath:  config.StatePath(),
+	}, nil
+}
+
+// newClient creates the Cloudflare client for cfg's auth mode with the secret from the keychain.
+func newClient(cfg config.Config, opts []cloudflare.Option) (*cloudflare.Client, error) {
+	var (
+		cfClient *cloudflare.Client
+		err      error
+	)
+	if cfg.AuthMode() == config.AuthGlobalKey {
+		key, keyErr := keychain.GetGlobalKey()
+		if keyErr != nil {
+			return nil, fmt.Errorf("failed to get Global API Key from keychain: %w", keyErr)
+		}
+		cfClient, err = cloudflare.NewWithGlobalKey(key, cfg.Email, opts...)
 	} else {
-		slog.Info("Successfully enabled Cloudflare proxy for DNS record", "hostname", hostname, "ip", currentIP.String())
+		token, tokenErr := keychain.Get()
+		if tokenErr != nil {
+			return nil, fmt.Errorf("failed to get API token from keychain: %w", tokenErr)
+		}
+		cfClient, err = cloudflare.New(token, opts...)
 	}
-	return result
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloudflare client: %w", err)
+	}
+	return cfClient, nil
+}
+
+// clientOptions translates the [api] config section into client options.
+func clientOptions(api config.APIConfig) []cloudflare.Option {
+	var opts []cloudflare.Option
+	if api.BaseURL != "" {
+		opts = append(opts, cloudflare.WithBaseURL(api.BaseURL))
+	}
+	if api.UserAgent != "" {
+		opts = append(opts, cloudflare.WithUserAgent(api.UserAgent))
+	}
+	if api.Timeout > 0 {
+		opts = append(opts, cloudflare.WithTimeout(api.Timeout))
+	}
+	return opts
 }
 
-// RunOnceWithCreate performs a single update cycle, creating the DNS record if it doesn't exist.
+// RunOnce performs a single update cycle: fetch public IP, compare with Cloudflare records, update if needed.
+// The public address is looked up once per family and shared by every configured record.
+func (u *Updater) RunOnce(ctx context.Context) CycleResult {
+	return u.run(ctx, false)
+}
+
+// RunOnceWithCreate performs a single update cycle, creating the DNS records if they don't exist.
 // This is useful during initial setup when the record may not have been created yet.
-func RunOnceWithCreate(ctx context.Context, hostname string) UpdateResult {
-	result := UpdateResult{}
+func (u *Updater) RunOnceWithCreate(ctx context.Context) CycleResult {
+	return u.run(ctx, true)
+}
 
-	// Get current public IP
-	currentIP, err := ip.Get()
+// RunOnce creates an Updater for cfg and performs a single update cycle with it.
+func RunOnce(ctx context.Context, cfg config.Config) CycleResult {
+	u, err := New(cfg)
 	if err != nil {
-		result.Error = fmt.Errorf("failed to get public IP: %w", err)
-		slog.Error("Failed to get public IP", "error", err)
-		return result
+		slog.Error("Failed to start update cycle", "error", err)
+		return CycleResult{Error: err}
 	}
-	result.CurrentIP = currentIP
+	return u.RunOnce(ctx)
+}
 
-	// Get API token from keychain
-	token, err := keychain.Get()
+// RunOnceWithCreate creates an Updater for cfg and performs a single update
+// cycle with it, creating the DNS records if they don't exist.
+func RunOnceWithCreate(ctx context.Context, cfg config.Config) CycleResult {
+	u, err := New(cfg)
 	if err != nil {
-		result.Error = fmt.Errorf("failed to get API token from keychain: %w", err)
-		slog.Error("Failed to get API token", "error", err)
-		return result
+		slog.Error("Failed to start update cycle", "error", err)
+		return CycleResult{Error: err}
 	}
+	return u.RunOnceWithCreate(ctx)
+}
 
-	// Create Cloudflare client
-	cfClient, err := cloudflare.New(token)
-	if err != nil {
-		result.Error = fmt.Errorf("failed to create Cloudflare client: %w", err)
-		slog.Error("Failed to create Cloudflare client", "error", err)
+func (u *Updater) run(ctx context.Context, create bool) CycleResult {
+	result := CycleResult{}
+	start := time.Now()
+
+	// Don't touch the API while a rate-limit backoff window is open
+	if until := u.client.RateLimitedUntil(); !until.IsZero() {
+		result.Error = &cloudflare.RateLimitError{Until: until}
+		result.RetryAt = until
+		slog.Warn("Skipping update cycle while rate limited", "retryAt", until.Format(time.RFC3339))
 		return result
 	}
 
-	// Get or create DNS record
-	record, err := cfClient.GetRecordOrCreate(ctx, hostname, currentIP)
-	if err != nil {
-		result.Error = fmt.Errorf("failed to get or create DNS record: %w", err)
-		slog.Error("Failed to get or create DNS record", "error", err, "hostname", hostname)
-		return result
+	records := u.cfg.AllRecords()
+	var types []string
+	for _, rec := range records {
+		types = append(types, rec.RecordTypes()...)
 	}
-	result.RecordIP = record.IP
-	result.OldIP = record.IP
-	result.RecordProxied = record.Proxied
+	addrs := u.detectAddresses(ctx, append(types, u.targetTypes()...))
 
-	// Check if IP or proxy status needs update
-	ipNeedsUpdate := !currentIP.Equal(record.IP)
-	proxyDisabled := record.Proxied == nil || !*record.Proxied
-	needsUpdate := ipNeedsUpdate || proxyDisabled
+	for _, rec := range records {
+		res := UpdateResult{Hostname: rec.Hostname}
+		for _, recordType := range rec.RecordTypes() {
+			fr := u.updateFamily(ctx, rec, recordType, addrs[recordType], create || (u.claimed && rec.IsEphemeral()))
+			if recordType == config.TypeAAAA {
+				res.IPv6 = fr
+			} else {
+				res.IPv4 = fr
+			}
 
-	if !needsUpdate {
-		slog.Info("DNS record is already up to date", "hostname", hostname, "ip", currentIP.String())
-		return result
+			if result.RetryAt = retryAt(fr.Error); !result.RetryAt.IsZero() {
+				break
+			}
+		}
+		if result.RetryAt.IsZero() {
+			res.Heartbeat = u.updateHeartbeat(ctx, rec, start)
+			if res.Heartbeat != nil {
+				result.RetryAt = retryAt(res.Heartbeat.Error)
+			}
+		}
+		result.Records = append(result.Records, res)
+
+		if !result.RetryAt.IsZero() {
+			slog.Warn("Rate limited by Cloudflare, skipping remaining records", "retryAt", result.RetryAt.Format(time.RFC3339))
+			break
+		}
 	}
 
-	// Update the record with the current IP
-	updatedRecord, err := cfClient.UpdateRecord(ctx, hostname, currentIP)
-	if err != nil {
-		result.Error = fmt.Errorf("failed to update DNS record: %w", err)
-		slog.Error("Failed to update DNS record", "error", err, "hostname", hostname, "oldIP", record.IP.String(), "newIP", currentIP.String())
+	// Apply the record changes planned above together, one batch per zone
+	u.applyUpdates(ctx, result.Records)
+	if result.RetryAt.IsZero() {
+		result.RetryAt = recordsRetryAt(result.Records)
+	}
+	if !result.RetryAt.IsZero() {
 		return result
 	}
 
-	// Update result with the latest record state
-	result.RecordIP = updatedRecord.IP
-	result.RecordProxied = updatedRecord.Proxied
-
-	result.Updated = true
-	if ipNeedsUpdate {
-		slog.Info("Successfully updated DNS record IP", "hostname", hostname, "oldIP", record.IP.String(), "newIP", currentIP.String())
-	} else {
-		slog.Info("Successfully enabled Cloudflare proxy for DNS record", "hostname", hostname, "ip", currentIP.String())
+	result.Targets = u.updateTargets(ctx, addrs)
+	for _, t := range result.Targets {
+		if until := retryAt(t.Error); !until.IsZero() {
+			result.RetryAt = until
+			slog.Warn("Rate limited by Cloudflare, skipping remaining targets", "retryAt", until.Format(time.RFC3339))
+		}
 	}
 	return result
 }
+
+// isRateLimited reports whether err is a Cloudflare rate limit.
+func isRateLimited(err error) bool {
+	return errors.Is(err, cloudflare.ErrRateLimited)
+}
+
+// retryAt returns when a rate-limited request may be retried, or the zero time if err is not a rate limit.
+func retryAt(err error) time.Time {
+	var rateLimited *cloudflare.RateLimitError
+	if errors.As(err, &rateLimited) {
+		return rateLimited.Until
+	}
+	return time.Time{}
+}
+
+// recordsRetryAt returns when the first rate-limited record may be retried,
+// or the zero time if no record was rate limited.
+func recordsRetryAt(records []UpdateResult) time.Time {
+	for _, r := range records {
+		for _, f := range r.Families() {
+			if until := retryAt(f.Error); !until.IsZero() {
+				slog.Warn("Rate limited by Cloudflare while updating records, skipping targets", "retryAt", until.Format(time.RFC3339))
+				return until
+			}
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,322 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	cf "github.com/cloudflare/cloudflare-go"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/cloudflare"
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
+	"github.com/jon-frankel/cloudflare-ddns/internal/ip"
+)
+
+// fakeAPI is a fake Cloudflare API serving the example.com zone. It keeps
+// the zone's DNS records, so tests can see what the updater wrote and edit
+// records behind its back like someone in the dashboard would.
+type fakeAPI struct {
+	mu      sync.Mutex
+	records map[string]*cf.DNSRecord
+	nextID  int
+	// listItems holds the items of the IP list "home_ips" (ID list-1) in account acct-1.
+	listItems []cf.ListItem
+	// accessRules holds the zone's IP access rules.
+	accessRules []cf.AccessRule
+	// gateway holds the source networks of the Gateway location "Branch
+	// office" (ID loc-1) in account acct-1.
+	gateway []string
+}
+
+func newFakeAPI(records ...cf.DNSRecord) *fakeAPI {
+	api := &fakeAPI{records: make(map[string]*cf.DNSRecord)}
+	for _, r := range records {
+		api.add(r)
+	}
+	return api
+}
+
+// add stores record, giving it an ID if it has none, and returns the ID.
+func (a *fakeAPI) add(record cf.DNSRecord) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if record.ID == "" {
+		a.nextID++
+		record.ID = fmt.Sprintf("rec-new-%d", a.nextID)
+	}
+	if record.Proxied == nil {
+		record.Proxied = cf.BoolPtr(true)
+	}
+	a.records[record.ID] = &record
+	return record.ID
+}
+
+// get returns a copy of the record with the given ID, or nil if it was deleted.
+func (a *fakeAPI) get(id string) *cf.DNSRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	r, ok := a.records[id]
+	if !ok {
+		return nil
+	}
+	copied := *r
+	return &copied
+}
+
+// edit changes a record's content, as an edit in the dashboard would.
+func (a *fakeAPI) edit(id, content string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records[id].Content = content
+}
+
+// listValues returns the addresses in the IP list.
+func (a *fakeAPI) listValues() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var values []string
+	for _, item := range a.listItems {
+		values = append(values, *item.IP)
+	}
+	slices.Sort(values)
+	return values
+}
+
+// accessRuleValues returns the addresses of the access rules with the given notes.
+func (a *fakeAPI) accessRuleValues(notes string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var values []string
+	for _, rule := range a.accessRules {
+		if rule.Notes == notes {
+			values = append(values, rule.Configuration.Value)
+		}
+	}
+	return values
+}
+
+// gatewayNetworks returns the Gateway location's source networks.
+func (a *fakeAPI) gatewayNetworks() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.gateway)
+}
+
+// named returns the records with the given name and type.
+func (a *fakeAPI) named(name, recordType string) []cf.DNSRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var found []cf.DNSRecord
+	for _, r := range a.records {
+		if r.Name == name && (recordType == "" || r.Type == recordType) {
+			found = append(found, *r)
+		}
+	}
+	return found
+}
+
+func (a *fakeAPI) handler(t *testing.T) http.Handler {
+	respond := func(w http.ResponseWriter, result any) {
+		body, err := json.Marshal(result)
+		if err != nil {
+			t.Errorf("Failed to encode fake response: %v", err)
+		}
+		fmt.Fprintf(w, `{"success":true,"errors":[],"messages":[],"result":%s,
+			"result_info":{"page":1,"per_page":100,"count":1,"total_count":1,"total_pages":1}}`, body)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /client/v4/zones", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, []map[string]string{{"id": "zone-1", "name": "example.com"}})
+	})
+	mux.HandleFunc("GET /client/v4/zones/zone-1/dns_records", func(w http.ResponseWriter, r *http.Request) {
+		found := a.named(r.URL.Query().Get("name"), r.URL.Query().Get("type"))
+		if found == nil {
+			found = []cf.DNSRecord{}
+		}
+		respond(w, found)
+	})
+	mux.HandleFunc("POST /client/v4/zones/zone-1/dns_records", func(w http.ResponseWriter, r *http.Request) {
+		var record cf.DNSRecord
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			t.Errorf("Failed to decode created record: %v", err)
+		}
+		record.CreatedOn = time.Now()
+		respond(w, a.get(a.add(record)))
+	})
+	mux.HandleFunc("PATCH /client/v4/zones/zone-1/dns_records/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var patch cf.DNSRecord
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			t.Errorf("Failed to decode record update: %v", err)
+		}
+
+		a.mu.Lock()
+		record, ok := a.records[r.PathValue("id")]
+		if ok {
+			if patch.Content != "" {
+				record.Content = patch.Content
+			}
+			if patch.Comment != "" {
+				record.Comment = patch.Comment
+			}
+			if patch.Proxied != nil {
+				record.Proxied = patch.Proxied
+			}
+			if patch.TTL != 0 {
+				record.TTL = patch.TTL
+			}
+		}
+		a.mu.Unlock()
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"success":false,"errors":[{"code":81044,"message":"Record does not exist."}],"messages":[],"result":null}`)
+			return
+		}
+		respond(w, a.get(r.PathValue("id")))
+	})
+	mux.HandleFunc("DELETE /client/v4/zones/zone-1/dns_records/{id}", func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		delete(a.records, r.PathValue("id"))
+		a.mu.Unlock()
+		respond(w, map[string]string{"id": r.PathValue("id")})
+	})
+	mux.HandleFunc("GET /client/v4/zones/zone-1/firewall/access_rules/rules", func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		rules := slices.Clone(a.accessRules)
+		a.mu.Unlock()
+		respond(w, rules)
+	})
+	mux.HandleFunc("POST /client/v4/zones/zone-1/firewall/access_rules/rules", func(w http.ResponseWriter, r *http.Request) {
+		var rule cf.AccessRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			t.Errorf("Failed to decode access rule: %v", err)
+		}
+		a.mu.Lock()
+		a.nextID++
+		rule.ID = fmt.Sprintf("rule-new-%d", a.nextID)
+		a.accessRules = append(a.accessRules, rule)
+		a.mu.Unlock()
+		respond(w, rule)
+	})
+	mux.HandleFunc("DELETE /client/v4/zones/zone-1/firewall/access_rules/rules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		a.accessRules = slices.DeleteFunc(a.accessRules, func(rule cf.AccessRule) bool { return rule.ID == r.PathValue("id") })
+		a.mu.Unlock()
+		respond(w, map[string]string{"id": r.PathValue("id")})
+	})
+	mux.HandleFunc("GET /client/v4/accounts/acct-1/rules/lists", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, []map[string]string{{"id": "list-1", "name": "home_ips", "kind": "ip"}})
+	})
+	mux.HandleFunc("GET /client/v4/accounts/acct-1/rules/lists/list-1/items", func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		items := slices.Clone(a.listItems)
+		a.mu.Unlock()
+		respond(w, items)
+	})
+	mux.HandleFunc("POST /client/v4/accounts/acct-1/rules/lists/list-1/items", func(w http.ResponseWriter, r *http.Request) {
+		var created []cf.ListItemCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+			t.Errorf("Failed to decode list items: %v", err)
+		}
+		a.mu.Lock()
+		for _, item := range created {
+			a.nextID++
+			a.listItems = append(a.listItems, cf.ListItem{ID: fmt.Sprintf("item-%d", a.nextID), IP: item.IP, Comment: item.Comment})
+		}
+		a.mu.Unlock()
+		respond(w, map[string]string{"operation_id": "op-1"})
+	})
+	mux.HandleFunc("DELETE /client/v4/accounts/acct-1/rules/lists/list-1/items", func(w http.ResponseWriter, r *http.Request) {
+		var deleted cf.ListItemDeleteRequest
+		if err := json.NewDecoder(r.Body).Decode(&deleted); err != nil {
+			t.Errorf("Failed to decode deleted list items: %v", err)
+		}
+		a.mu.Lock()
+		for _, d := range deleted.Items {
+			a.listItems = slices.DeleteFunc(a.listItems, func(item cf.ListItem) bool { return item.ID == d.ID })
+		}
+		a.mu.Unlock()
+		respond(w, map[string]string{"operation_id": "op-2"})
+	})
+	mux.HandleFunc("GET /client/v4/accounts/acct-1/rules/lists/bulk_operations/{id}", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, map[string]string{"id": r.PathValue("id"), "status": "completed"})
+	})
+	mux.HandleFunc("GET /client/v4/accounts/acct-1/gateway/locations", func(w http.ResponseWriter, r *http.Request) {
+		var networks []map[string]string
+		for _, n := range a.gatewayNetworks() {
+			networks = append(networks, map[string]string{"network": n})
+		}
+		respond(w, []map[string]any{{"id": "loc-1", "name": "Branch office", "networks": networks}})
+	})
+	mux.HandleFunc("PUT /client/v4/accounts/acct-1/gateway/locations/loc-1", func(w http.ResponseWriter, r *http.Request) {
+		var loc struct {
+			Networks []struct {
+				Network string `json:"network"`
+			} `json:"networks"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&loc); err != nil {
+			t.Errorf("Failed to decode Gateway location: %v", err)
+		}
+		a.mu.Lock()
+		a.gateway = nil
+		for _, n := range loc.Networks {
+			a.gateway = append(a.gateway, n.Network)
+		}
+		a.mu.Unlock()
+		respond(w, map[string]string{"id": "loc-1"})
+	})
+	return mux
+}
+
+// fixedProvider answers with whatever addresses the test set last.
+type fixedProvider struct {
+	ip net.IP
+	// ipv6 is the IPv6 address, for tests that need both families.
+	ipv6 net.IP
+}
+
+func (p *fixedProvider) Name() string { return "fixed" }
+
+func (p *fixedProvider) Get(_ context.Context, family ip.Family) (net.IP, error) {
+	for _, addr := range []net.IP{p.ip, p.ipv6} {
+		if addr != nil && (addr.To4() != nil) == (family == ip.IPv4) {
+			return addr, nil
+		}
+	}
+	return nil, fmt.Errorf("fixed has no %s address", family)
+}
+
+// newTestUpdater creates an Updater for cfg that talks to api and detects
+// the public address with provider.
+func newTestUpdater(t *testing.T, api *fakeAPI, cfg config.Config, provider *fixedProvider) *Updater {
+	t.Helper()
+	t.Setenv("CLOUDFLARE_API_TOKEN", "test-token")
+
+	srv := httptest.NewServer(api.handler(t))
+	t.Cleanup(srv.Close)
+
+	if cfg.MachineName == "" {
+		cfg.MachineName = "test-host"
+	}
+	u, err := New(cfg, cloudflare.WithBaseURL(srv.URL+"/client/v4/"), cloudflare.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	u.providers = []ip.Provider{provider}
+	u.statePath = filepath.Join(t.TempDir(), "state.json")
+	return u
+}
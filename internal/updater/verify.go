@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jon-frankel/cloudflare-ddns/internal/cloudflare"
+	"github.com/jon-frankel/cloudflare-ddns/internal/config"
+)
+
+// VerifyResult is the outcome of checking the credentials against the configured records.
+type VerifyResult struct {
+	// AuthMode is the configured auth mode, config.AuthToken or config.AuthGlobalKey.
+	AuthMode string
+	// Token is the API token's status; nil with the Global API Key.
+	Token *cloudflare.TokenStatus
+	// Email is the account the Global API Key belongs to; empty with an API token.
+	Email string
+	// Checks holds one permission check per configured hostname, in config order.
+	Checks []cloudflare.PermissionCheck
+	// Error is set when the token or key itself could not be verified.
+	Error error
+}
+
+// OK returns true if the credentials are valid and have every permission they need.
+func (v VerifyResult) OK() bool {
+	if v.Error != nil || (v.AuthMode == config.AuthToken && (v.Token == nil || !v.Token.Active())) {
+		return false
+	}
+	for _, c := range v.Checks {
+		if !c.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify checks that the API token is active, or the Global API Key valid,
+// and may read the zone and edit the DNS records of every configured
+// hostname. Nothing is modified.
+func (u *Updater) Verify(ctx context.Context) VerifyResult {
+	result := VerifyResult{AuthMode: u.cfg.AuthMode()}
+
+	if result.AuthMode == config.AuthGlobalKey {
+		email, err := u.client.VerifyGlobalKey(ctx)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		result.Email = email
+	} else {
+		status, err := u.client.VerifyToken(ctx)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		result.Token = status
+		if !status.Active() {
+			return result
+		}
+	}
+
+	for _, rec := range u.cfg.AllRecords() {
+		result.Checks = append(result.Checks, u.client.CheckPermissions(ctx, rec.Hostname))
+	}
+	return result
+}
+
+// CredentialsRejected reports whether Cloudflare no longer accepts the API
+// token or Global API Key at all, as opposed to refusing a request for lack
+// of a permission on one zone or account. If Cloudflare cannot be asked, the
+// credentials are assumed to be fine.
+func (u *Updater) CredentialsRejected(ctx context.Context) bool {
+	if u.cfg.AuthMode() == config.AuthGlobalKey {
+		_, err := u.client.VerifyGlobalKey(ctx)
+		return errors.Is(err, cloudflare.ErrAuth)
+	}
+	status, err := u.client.VerifyToken(ctx)
+	if err != nil {
+		return errors.Is(err, cloudflare.ErrAuth)
+	}
+	return !status.Active()
+}
+
+// Verify creates an Updater for cfg and checks its credentials with it.
+func Verify(ctx context.Context, cfg config.Config) VerifyResult {
+	u, err := New(cfg)
+	if err != nil {
+		return VerifyResult{Error: err}
+	}
+	return u.Verify(ctx)
+}